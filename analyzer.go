@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// runAnalyzer runs Analyzer, and does not return, if the command line asks for it:
+// either the vet subcommand, which takes the flags and patterns of a singlechecker,
+// or the protocol of go vet -vettool.
+// The flags of the CLI do not apply, so they are dropped first.
+func runAnalyzer() {
+	args := os.Args[1:]
+	switch {
+	case len(args) > 0 && args[0] == "vet":
+		flag.CommandLine = flag.NewFlagSet(os.Args[0]+" vet", flag.ExitOnError)
+		os.Args = append([]string{os.Args[0]}, args[1:]...)
+		singlechecker.Main(iverson.Analyzer)
+	case vetTool(args):
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		unitchecker.Main(iverson.Analyzer)
+	}
+}
+
+// vetTool reports whether args are from go vet, which first asks for the version and flags of the tool
+// and then runs it on the JSON config file of each package.
+func vetTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	last := args[len(args)-1]
+	return args[0] == "-V=full" || args[0] == "-flags" || strings.HasSuffix(last, ".cfg")
+}
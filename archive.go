@@ -0,0 +1,133 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extractArchive extracts the .zip, .tar, .tar.gz, or .tgz file at name into a new temporary directory.
+// It returns the root of the extracted source,
+// which is the only top level directory if there is one, as in module zips,
+// and a func to remove everything.
+func extractArchive(name string) (root string, cleanup func(), err error) {
+	tmp, err := os.MkdirTemp("", "issue61915-archive-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = extractZip(tmp, name)
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		err = extractTar(tmp, name, true)
+	case strings.HasSuffix(name, ".tar"):
+		err = extractTar(tmp, name, false)
+	default:
+		err = fmt.Errorf("%s: unknown archive format: want .zip, .tar, .tar.gz, or .tgz", name)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	root = tmp
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(tmp, entries[0].Name())
+	}
+	return root, func() { os.RemoveAll(tmp) }, nil
+}
+
+func extractZip(dir, name string) error {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(dir, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func extractTar(dir, name string, gzipped bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		// only regular files matter for analysis
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := writeFile(dir, hdr.Name, tr); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+// writeFile writes the archive member with the slash separated name under dir,
+// refusing names that would escape it.
+func writeFile(dir, name string, r io.Reader) error {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("invalid path %q in archive", name)
+	}
+	dst := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
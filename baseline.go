@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// baseline is the state kept by -baseline: the findings of the snapshot in the file,
+// or with -write-baseline, those of this run to replace it with.
+type baseline struct {
+	file  string
+	write bool
+
+	prev  []baselined
+	ids   map[string]bool // IDs of prev
+	moves *moves          // by the IDs of packages
+
+	records []jsonFinding
+	pkgs    []jsonPackage
+	total   map[string]int
+}
+
+// A baselined finding is one in the snapshot, with the ID of its package.
+type baselined struct {
+	pkg string
+	f   iverson.Finding
+}
+
+// loadBaseline reads the snapshot written to file by -write-baseline, in the records of -format=json.
+// If write, the snapshot is not read but replaced when the run is done.
+func loadBaseline(file string, write bool) (*baseline, error) {
+	b := &baseline{file: file, write: write, ids: map[string]bool{}, moves: newMoves(), total: map[string]int{}}
+	if write {
+		return b, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ps, found, _, err := readFindings(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for i, pkg := range ps {
+		for _, f := range found[i] {
+			b.prev = append(b.prev, baselined{pkg.ID, f})
+			b.ids[f.ID] = true
+			b.moves.earlier(f.ID, f.Content, pkg.ID)
+		}
+	}
+	return b, nil
+}
+
+// load records the packages loaded this run, to tell the findings that moved from those that did not.
+func (b *baseline) load(ps []*packages.Package) {
+	for _, pkg := range ps {
+		b.moves.loaded[pkg.ID] = true
+	}
+}
+
+// filter records the findings in pkg and returns those not in the snapshot,
+// nor moved from another package in it, as by moves,
+// or all of them if the snapshot is being written.
+func (b *baseline) filter(pkg *packages.Package, found []iverson.Finding) []iverson.Finding {
+	if b.write {
+		counts := map[string]int{}
+		for _, f := range found {
+			b.records = append(b.records, newJSONFinding(pkg, f))
+			counts[f.Kind]++
+			b.total[f.Kind]++
+		}
+		if len(counts) > 0 {
+			b.pkgs = append(b.pkgs, newJSONPackage(pkg, counts))
+		}
+		return found
+	}
+	b.moves.analyze(pkg.ID, found)
+	var added []iverson.Finding
+	for _, f := range found {
+		if b.ids[f.ID] {
+			continue
+		}
+		if _, moved := b.moves.match(pkg.ID, f); moved {
+			continue
+		}
+		added = append(added, f)
+	}
+	return added
+}
+
+// removed returns the findings in the snapshot that were not found again, nor moved to another package,
+// in the order they were written.
+// Those in packages not analyzed, as with -pkg-filter or -budget, are only removed if their file is gone,
+// as it is when the whole package is.
+func (b *baseline) removed() []baselined {
+	var gone []baselined
+	for _, p := range b.prev {
+		if b.moves.seen[p.f.ID] || b.moves.moved[p.f.ID] {
+			continue
+		}
+		if b.moves.analyzed[p.pkg] {
+			gone = append(gone, p)
+		} else if _, err := os.Stat(p.f.Pos.Filename); errors.Is(err, fs.ErrNotExist) {
+			gone = append(gone, p)
+		}
+	}
+	return gone
+}
+
+// save writes the findings of this run to the snapshot if -write-baseline.
+func (b *baseline) save() error {
+	if !b.write {
+		return nil
+	}
+	f, err := os.Create(b.file)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range b.records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	sum := jsonSummary{Record: "summary", Packages: b.pkgs, Total: b.total}
+	if sum.Packages == nil {
+		sum.Packages = []jsonPackage{}
+	}
+	if err := enc.Encode(sum); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
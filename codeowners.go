@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// codeowners maps files to their owners by the rules of a CODEOWNERS file.
+type codeowners struct {
+	root  string // directory the patterns are relative to
+	rules []ownerRule
+}
+
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  string // space separated, empty if the pattern explicitly has no owners
+}
+
+// parseCodeowners reads the CODEOWNERS file at name.
+// The patterns are relative to the directory containing it,
+// or its parent if that is .github or docs.
+func parseCodeowners(name string) (*codeowners, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	root, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	if base := filepath.Base(root); base == ".github" || base == "docs" {
+		root = filepath.Dir(root)
+	}
+	co := &codeowners{root: root}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := ownerPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		co.rules = append(co.rules, ownerRule{re, strings.Join(fields[1:], " ")})
+	}
+	return co, sc.Err()
+}
+
+// ownerPattern translates a gitignore style CODEOWNERS pattern to a regexp matching slash separated paths relative to the root.
+func ownerPattern(p string) (*regexp.Regexp, error) {
+	dir := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	// a slash anywhere but the end anchors the pattern to the root
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(.*/)?")
+	}
+	for i, seg := range strings.Split(p, "/") {
+		if i > 0 {
+			b.WriteString("/")
+		}
+		if seg == "**" {
+			b.WriteString(".*")
+			continue
+		}
+		for _, r := range seg {
+			switch r {
+			case '*':
+				b.WriteString("[^/]*")
+			case '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+	if dir {
+		b.WriteString("/.*$")
+	} else {
+		b.WriteString("(/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// owner returns the owners of the file with the given path, by the last matching rule,
+// or "" if it has none.
+func (co *codeowners) owner(path string) string {
+	rel, err := filepath.Rel(co.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	rel = filepath.ToSlash(rel)
+	for i := len(co.rules) - 1; i >= 0; i-- {
+		if co.rules[i].pattern.MatchString(rel) {
+			return co.rules[i].owners
+		}
+	}
+	return ""
+}
+
+// attribute fills in the Owner of findings.
+func (co *codeowners) attribute(found []iverson.Finding) {
+	for i, f := range found {
+		found[i].Owner = co.owner(f.Pos.Filename)
+	}
+}
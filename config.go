@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// configFiles are the names of the configuration files looked for in the working directory without -config.
+var configFiles = []string{"iverson.toml", ".iverson.yaml"}
+
+// A setting is one key of a configuration file and its value,
+// a list of strings if the value was one.
+type setting struct {
+	line   int
+	key    string
+	values []string
+	list   bool
+}
+
+// applyConfig reads the configuration file named by -config, or else the one of configFiles in the working directory,
+// and sets each flag it names that was not set on the command line.
+// It returns the name of the file, if there was one, and the patterns it sets,
+// to analyze if none are given on the command line.
+//
+// The keys are the names of the flags, as in fail-on = "warning" or returns = true,
+// with lists of strings for those taking comma separated lists, as in exclude = ["example.com/m/internal/..."],
+// and patterns for the list of patterns.
+// Only flat files of such keys are understood: no TOML tables, or YAML mappings within the top level one.
+func applyConfig() (string, []string, error) {
+	file := *confFile
+	switch file {
+	case "none":
+		return "", nil, nil
+	case "":
+		for _, name := range configFiles {
+			if _, err := os.Stat(name); errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return "", nil, err
+			}
+			if file != "" {
+				return "", nil, fmt.Errorf("both %s and %s configure the run: remove one", file, name)
+			}
+			file = name
+		}
+		if file == "" {
+			return "", nil, nil
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	parse := parseTOML
+	if strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml") {
+		parse = parseYAML
+	}
+	settings, err := parse(string(data))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s:%w", file, err)
+	}
+
+	onCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	var patterns []string
+	seen := map[string]bool{}
+	for _, s := range settings {
+		if seen[s.key] {
+			return "", nil, fmt.Errorf("%s:%d: %s is set twice", file, s.line, s.key)
+		}
+		seen[s.key] = true
+		if s.key == "patterns" {
+			patterns = s.values
+			continue
+		}
+		f := flag.Lookup(s.key)
+		if f == nil || s.key == "config" {
+			return "", nil, fmt.Errorf("%s:%d: unknown setting %q", file, s.line, s.key)
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && s.list {
+			return "", nil, fmt.Errorf("%s:%d: %s takes true or false, not a list", file, s.line, s.key)
+		}
+		if onCommandLine[s.key] {
+			continue
+		}
+		if err := f.Value.Set(strings.Join(s.values, ",")); err != nil {
+			return "", nil, fmt.Errorf("%s:%d: %s: %w", file, s.line, s.key, err)
+		}
+	}
+	return file, patterns, nil
+}
+
+// configKey matches the keys of settings.
+var configKey = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// parseTOML parses the settings of an iverson.toml file: key = value lines,
+// where each value is a string, bool, number, or array of them, which may go over several lines.
+// Errors start with the line number, to follow the name of the file.
+func parseTOML(data string) ([]setting, error) {
+	var settings []setting
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := i + 1
+		text := strings.TrimSpace(stripComment(lines[i]))
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			return nil, fmt.Errorf("%d: tables are not supported", line)
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !configKey.MatchString(key) || value == "" {
+			return nil, fmt.Errorf("%d: want key = value", line)
+		}
+		// an array continues until its brackets close
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		values, list, err := parseValue(value, false)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %w", line, key, err)
+		}
+		settings = append(settings, setting{line, key, values, list})
+	}
+	return settings, nil
+}
+
+// parseYAML parses the settings of an .iverson.yaml file: key: value lines,
+// where each value is a scalar or a flow sequence of them,
+// or, if empty, the block sequence of "- value" lines indented under it.
+// Errors start with the line number, to follow the name of the file.
+func parseYAML(data string) ([]setting, error) {
+	var settings []setting
+	var block *setting // the setting a block sequence is being read for, if any
+	for i, raw := range strings.Split(data, "\n") {
+		line := i + 1
+		text := strings.TrimSpace(stripComment(raw))
+		if text == "" || (line == 1 && text == "---") {
+			continue
+		}
+		if raw[0] == ' ' || raw[0] == '\t' || strings.HasPrefix(text, "- ") || text == "-" {
+			item, ok := strings.CutPrefix(text, "-")
+			if !ok || block == nil {
+				return nil, fmt.Errorf("%d: want key: value", line)
+			}
+			values, list, err := parseValue(strings.TrimSpace(item), true)
+			if err != nil || list {
+				return nil, fmt.Errorf("%d: %s: want a scalar in the list", line, block.key)
+			}
+			block.values = append(block.values, values...)
+			continue
+		}
+		block = nil
+		key, value, ok := strings.Cut(text, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !configKey.MatchString(key) {
+			return nil, fmt.Errorf("%d: want key: value", line)
+		}
+		if value == "" {
+			settings = append(settings, setting{line: line, key: key, list: true})
+			block = &settings[len(settings)-1]
+			continue
+		}
+		values, list, err := parseValue(value, true)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %w", line, key, err)
+		}
+		settings = append(settings, setting{line, key, values, list})
+	}
+	return settings, nil
+}
+
+// parseValue parses a scalar, a quoted string, or a bracketed list of them,
+// returning the strings and whether they were a list.
+// Unquoted scalars other than bools and numbers are only allowed in YAML.
+func parseValue(value string, yaml bool) ([]string, bool, error) {
+	inner, list := strings.CutPrefix(value, "[")
+	if !list {
+		s, err := parseScalar(value, yaml)
+		return []string{s}, false, err
+	}
+	inner, ok := strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, false, errors.New("unclosed list")
+	}
+	var values []string
+	for _, item := range splitList(inner) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			// a trailing comma
+			continue
+		}
+		s, err := parseScalar(item, yaml)
+		if err != nil {
+			return nil, false, err
+		}
+		values = append(values, s)
+	}
+	return values, true, nil
+}
+
+// parseScalar parses a quoted string, a bool, or a number, or in YAML any other plain scalar.
+func parseScalar(s string, yaml bool) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		inner, ok := strings.CutSuffix(s[1:], "'")
+		if !ok || len(s) < 2 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		if yaml {
+			inner = strings.ReplaceAll(inner, "''", "'")
+		}
+		return inner, nil
+	case yaml, s == "true", s == "false":
+		return s, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("cannot parse %s: quote strings", s)
+	}
+	return strings.ReplaceAll(s, "_", ""), nil
+}
+
+// stripComment removes a # comment from the end of line, unless the # is quoted.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitList splits the items of a list at the commas not in quotes.
+func splitList(s string) []string {
+	var items []string
+	var quote rune
+	escaped := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// consolidatedPkg is the path, under the root of the main module, of the package that -consolidate collects the helpers into.
+const consolidatedPkg = "internal/booleans"
+
+// A consolidatedHelper is a helper that -consolidate replaces by the func of consolidatedPkg for its result type.
+type consolidatedHelper struct {
+	key  string // the import path of its package and its name, like example.com/m.b2i
+	name string // the name of the func of consolidatedPkg replacing it, like ToInt
+	decl *ast.FuncDecl
+	file string // the name of the file declaring it
+	pkg  *packages.Package
+	kept bool // whether a use or the declaration cannot be rewritten, so the declaration must stay
+}
+
+// consolidate records the edits of -consolidate for the packages of the main module in ps:
+// if they declare more than one helper, a func from a bool to a basic number type whose body only returns 1 if it is true and 0 if not,
+// it adds consolidatedPkg to the root of the module with one such func for each result type, like ToInt,
+// unless an earlier run added it,
+// rewrites each use of the helpers into a use of those, importing the package,
+// and deletes each unexported helper once no use of it is left, in the packages loaded or in the files left out by build constraints.
+// Exported helpers are kept, as other modules may use them.
+// Files that -fix would leave alone, or where booleans names something else, are left as they are, and so are the helpers they use.
+func (x *fixer) consolidate(ps []*packages.Package) error {
+	var mod *packages.Module
+	var existing *packages.Package // consolidatedPkg, if an earlier run added it
+	helpers := map[string]*consolidatedHelper{}
+	var keys []string
+	for _, pkg := range ps {
+		if pkg.Module == nil || !pkg.Module.Main || pkg.TypesInfo == nil {
+			continue
+		}
+		if mod != nil && mod.Dir != pkg.Module.Dir {
+			return fmt.Errorf("-consolidate takes the packages of one module, not both %s and %s", mod.Path, pkg.Module.Path)
+		}
+		mod = pkg.Module
+		if pkg.PkgPath == mod.Path+"/"+consolidatedPkg {
+			existing = pkg
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				decl, ok := decl.(*ast.FuncDecl)
+				if !ok {
+					continue
+				}
+				fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+				if !ok {
+					continue
+				}
+				name, ok := consolidatedName(fn)
+				if !ok || !helperBody(pkg.TypesInfo, decl) {
+					continue
+				}
+				key := fn.Pkg().Path() + "." + fn.Name()
+				if helpers[key] != nil {
+					// the same file in another variant of the package, as with -tests
+					continue
+				}
+				helpers[key] = &consolidatedHelper{key: key, name: name, decl: decl, file: pkg.Fset.PositionFor(decl.Pos(), false).Filename, pkg: pkg}
+				keys = append(keys, key)
+			}
+		}
+	}
+	if len(helpers) < 2 {
+		slog.Info("nothing to consolidate", "helpers", len(helpers))
+		return nil
+	}
+	slices.Sort(keys)
+	path := mod.Path + "/" + consolidatedPkg
+	names := map[string]bool{} // the funcs of consolidatedPkg replacing them
+	for _, h := range helpers {
+		names[h.name] = true
+	}
+	if existing != nil {
+		for name := range names {
+			if existing.Types.Scope().Lookup(name) == nil {
+				return fmt.Errorf("-consolidate: %s has no %s", existing.PkgPath, name)
+			}
+		}
+	}
+
+	// the uses of each helper, rewritten once each file is known to take them all
+	type use struct {
+		h          *consolidatedHelper
+		start, end int
+		qual       *types.PkgName // the package qualifying the use, if any
+	}
+	done := map[string]bool{} // files already rewritten, in another variant of their package
+	for _, pkg := range ps {
+		if pkg.Module == nil || !pkg.Module.Main || pkg.TypesInfo == nil {
+			continue
+		}
+		for _, file := range pkg.Syntax {
+			name := pkg.Fset.PositionFor(file.FileStart, false).Filename
+			if done[name] {
+				continue
+			}
+			done[name] = true
+			var uses []use
+			reason := "" // why the uses in file cannot be rewritten
+			ast.Inspect(file, func(n ast.Node) bool {
+				var id *ast.Ident
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					// a use from another package, as pkg.B2i
+					if x, isIdent := n.X.(*ast.Ident); isIdent {
+						if qual, isPkg := pkg.TypesInfo.Uses[x].(*types.PkgName); isPkg {
+							if h := helperOf(helpers, pkg.TypesInfo.Uses[n.Sel]); h != nil {
+								uses = append(uses, use{h, fileOffset(pkg.Fset, n.Pos()), fileOffset(pkg.Fset, n.End()), qual})
+								if !booleansFree(pkg, n.Pos(), path) {
+									reason = "booleans names something else"
+								}
+							}
+							return false
+						}
+					}
+					return true
+				case *ast.Ident:
+					id = n
+				default:
+					return true
+				}
+				if h := helperOf(helpers, pkg.TypesInfo.Uses[id]); h != nil {
+					uses = append(uses, use{h, fileOffset(pkg.Fset, id.Pos()), fileOffset(pkg.Fset, id.End()), nil})
+					if !booleansFree(pkg, id.Pos(), path) {
+						reason = "booleans names something else"
+					}
+				}
+				return true
+			})
+			if len(uses) == 0 {
+				continue
+			}
+			if reason == "" && !x.fixable(name) {
+				reason = x.skipped[name]
+			}
+			if reason == "" && lineDirectives(file) {
+				reason = "line directives"
+			}
+			if reason != "" {
+				slog.Warn("not consolidating file", "file", name, "reason", reason)
+				for _, u := range uses {
+					u.h.kept = true
+				}
+				continue
+			}
+			ff, err := x.file(name)
+			if err != nil {
+				return err
+			}
+			quals := map[*types.PkgName]int{} // the uses of each package qualifying a use, all of which go
+			for _, u := range uses {
+				ff.edits = append(ff.edits, fixEdit{u.start, u.end, "booleans." + u.h.name})
+				if u.qual != nil {
+					quals[u.qual]++
+				}
+			}
+			ff.edits = append(ff.edits, importEdits(pkg, file, ff.src, path, quals)...)
+		}
+	}
+
+	for _, key := range keys {
+		h := helpers[key]
+		if h.decl.Name.IsExported() || h.kept || !x.fixable(h.file) || ignoredUse(h) {
+			slog.Info("keeping helper", "func", h.key)
+			continue
+		}
+		ff, err := x.file(h.file)
+		if err != nil {
+			return err
+		}
+		start := h.decl.Pos()
+		if h.decl.Doc != nil {
+			start = h.decl.Doc.Pos()
+		}
+		end := fileOffset(h.pkg.Fset, h.decl.End())
+		// and the line it ends
+		for end < len(ff.src) && ff.src[end] == '\n' {
+			end++
+		}
+		ff.edits = append(ff.edits, fixEdit{fileOffset(h.pkg.Fset, start), end, ""})
+		slog.Info("deleting helper", "func", h.key)
+	}
+
+	if existing != nil {
+		return nil
+	}
+	name := filepath.Join(mod.Dir, filepath.FromSlash(consolidatedPkg), "booleans.go")
+	if _, err := os.Stat(name); err == nil {
+		return fmt.Errorf("cannot add %s for -consolidate: it exists", name)
+	}
+	var b bytes.Buffer
+	b.WriteString("// Package booleans converts bools to numbers, as the helpers collected here by -consolidate did.\npackage booleans\n")
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		typ := strings.ToLower(strings.TrimPrefix(name, "To"))
+		fmt.Fprintf(&b, "\n// %s returns 1 if b is true and 0 if it is false.\nfunc %s(b bool) %s {\n\tif b {\n\t\treturn 1\n\t}\n\treturn 0\n}\n", name, name, typ)
+	}
+	x.added[name] = b.Bytes()
+	return nil
+}
+
+// consolidatedName returns the name of the func of consolidatedPkg that replaces fn, like ToInt for a func(bool) int,
+// if fn is a package level func, not a method or generic, from exactly a bool to a basic number type.
+func consolidatedName(fn *types.Func) (string, bool) {
+	sig := fn.Signature()
+	if sig.Recv() != nil || sig.TypeParams() != nil || sig.Params().Len() != 1 || sig.Results().Len() != 1 ||
+		!types.Identical(sig.Params().At(0).Type(), types.Typ[types.Bool]) {
+		return "", false
+	}
+	res, ok := sig.Results().At(0).Type().(*types.Basic)
+	if !ok || res.Info()&(types.IsInteger|types.IsFloat) == 0 || res.Info()&types.IsUntyped != 0 {
+		return "", false
+	}
+	// by the name of the type, not of an alias like byte
+	r := []rune(types.Typ[res.Kind()].Name())
+	r[0] = unicode.ToUpper(r[0])
+	return "To" + string(r), true
+}
+
+// helperOf returns the helper obj is, if it is one of helpers.
+func helperOf(helpers map[string]*consolidatedHelper, obj types.Object) *consolidatedHelper {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Parent() != fn.Pkg().Scope() {
+		return nil
+	}
+	return helpers[fn.Pkg().Path()+"."+fn.Name()]
+}
+
+// booleansFree reports whether booleans at pos in pkg names nothing, or the package at path.
+func booleansFree(pkg *packages.Package, pos token.Pos, path string) bool {
+	scope := pkg.Types.Scope().Innermost(pos)
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent("booleans", pos)
+	if obj == nil {
+		return true
+	}
+	name, ok := obj.(*types.PkgName)
+	return ok && name.Imported().Path() == path
+}
+
+// importEdits returns the edits to the imports of file, whose source is src,
+// that the uses of the helpers rewritten need: importing the package at path, unless file does already,
+// and removing the imports whose every use, as counted by uses, is rewritten.
+// The import of path takes the place of the first import removed, if any,
+// or else goes in the first parenthesized import declaration, which a single import becomes,
+// or after the package clause.
+func importEdits(pkg *packages.Package, file *ast.File, src []byte, path string, uses map[*types.PkgName]int) []fixEdit {
+	fset := pkg.Fset
+	add := true
+	for _, imp := range file.Imports {
+		if strings.Trim(imp.Path.Value, `"`) == path && imp.Name == nil {
+			add = false
+		}
+	}
+	used := map[*types.PkgName]int{}
+	for _, obj := range pkg.TypesInfo.Uses {
+		if name, ok := obj.(*types.PkgName); ok {
+			used[name]++
+		}
+	}
+	spec := fmt.Sprintf("%q", path)
+	var edits []fixEdit
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		for _, s := range gen.Specs {
+			imp := s.(*ast.ImportSpec)
+			name, ok := pkg.TypesInfo.Implicits[imp].(*types.PkgName)
+			if imp.Name != nil {
+				name, ok = pkg.TypesInfo.Defs[imp.Name].(*types.PkgName)
+			}
+			if !ok || uses[name] == 0 || uses[name] < used[name] {
+				continue
+			}
+			start, end := fileOffset(fset, imp.Pos()), fileOffset(fset, imp.End())
+			switch {
+			case add:
+				edits = append(edits, fixEdit{start, end, spec})
+				add = false
+			case gen.Lparen.IsValid():
+				// the whole line
+				for start > 0 && src[start-1] != '\n' {
+					start--
+				}
+				if end < len(src) && src[end] == '\n' {
+					end++
+				}
+				edits = append(edits, fixEdit{start, end, ""})
+			default:
+				edits = append(edits, fixEdit{fileOffset(fset, gen.Pos()), end, ""})
+			}
+		}
+	}
+	if !add {
+		return edits
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			break
+		}
+		if gen.Lparen.IsValid() {
+			at := fileOffset(fset, gen.Lparen) + 1
+			return append(edits, fixEdit{at, at, "\n\t" + spec})
+		}
+		s := gen.Specs[0]
+		start, end := fileOffset(fset, s.Pos()), fileOffset(fset, s.End())
+		return append(edits, fixEdit{start, end, "(\n\t" + string(src[start:end]) + "\n\t" + spec + "\n)"})
+	}
+	at := fileOffset(fset, file.Name.End())
+	return append(edits, fixEdit{at, at, "\n\nimport " + spec})
+}
+
+// ignoredUse reports whether any file of the package of h left out by build constraints mentions its name,
+// so that deleting it could break a build for another platform.
+func ignoredUse(h *consolidatedHelper) bool {
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(h.decl.Name.Name) + `\b`)
+	for _, name := range h.pkg.IgnoredFiles {
+		src, err := os.ReadFile(name)
+		if err != nil || word.Match(src) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// corpusModules are the modules given to the corpus subcommand, to analyze instead of the patterns.
+var corpusModules []string
+
+// dedupeFiles is the -dedupe-identical-files flag of the corpus subcommand.
+var dedupeFiles bool
+
+// loadRetries and retryWait are the -retries and -retry-wait flags of the corpus subcommand.
+var (
+	loadRetries int
+	retryWait   time.Duration
+)
+
+// corpusRepos are the repository URLs of the corpus modules, as path@version, that the module proxy reports.
+var corpusRepos = map[string]string{}
+
+// Corpus analyzes every package in each module named by args, as path@version or just path for the latest,
+// or on each line of the -list file in args, with how popular it is, like its number of importers, after it.
+// -top keeps the n most popular, those with a count first by it and then the rest in order.
+// Each module is downloaded through the module proxy and analyzed in a throwaway module of its own,
+// and the counts are broken down by module unless -by says otherwise,
+// and rolled up by the repository of each module.
+// A module that fails to download or load is retried with -retries and -retry-wait,
+// and if it still fails, logged, recorded in the results as failed, and skipped.
+// With -dedupe-identical-files, a file with the same contents as one of an earlier module is left out of the counts.
+// The flags for the output and analysis apply as they would to any run.
+func Corpus(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("corpus", flag.ContinueOnError)
+	list := fs.String("list", "", "also analyze the module@version on each line of this `file`, or stdin if -, each optionally followed by a count of how popular it is, like its number of importers")
+	top := fs.Int("top", 0, "only analyze the `n` most popular modules, by their counts in the -list, and then in order")
+	fs.IntVar(&loadRetries, "retries", 2, "retry loading a module that fails up to `n` times, as when the module proxy is flaky")
+	fs.DurationVar(&retryWait, "retry-wait", time.Second, "wait this `duration` before the first retry of a module, and twice as long before each after")
+	fs.BoolVar(&dedupeFiles, "dedupe-identical-files", false, "only count the findings in a file in the first module with a file of the same contents, so forks and copied files are counted once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	modules := fs.Args()
+	if *list != "" {
+		more, err := readTargets(*list)
+		if err != nil {
+			return err
+		}
+		modules = append(modules, more...)
+	}
+	if len(modules) == 0 || *top < 0 || loadRetries < 0 {
+		return errors.New("usage: corpus [-list file] [-top n] [-retries n] [-retry-wait duration] [-dedupe-identical-files] [module@version ...]")
+	}
+	modules, err := rankModules(modules)
+	if err != nil {
+		return err
+	}
+	if *top > 0 && *top < len(modules) {
+		modules = modules[:*top]
+	}
+	if *by == "" {
+		*by = "module"
+	}
+	corpusModules = modules
+	return Main(ctx, nil)
+}
+
+// rankModules returns the modules of entries, each a module optionally followed by a count of how popular it is,
+// most popular first: those with a count by it, most first, and then the rest, each in the order of entries.
+func rankModules(entries []string) ([]string, error) {
+	type ranked struct {
+		module string
+		count  int // -1 without one
+	}
+	rs := make([]ranked, len(entries))
+	for i, entry := range entries {
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 1:
+			rs[i] = ranked{fields[0], -1}
+		case 2:
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%q: the count of how popular a module is must be a non-negative integer", entry)
+			}
+			rs[i] = ranked{fields[0], n}
+		default:
+			return nil, fmt.Errorf("%q is not a module optionally followed by a count", entry)
+		}
+	}
+	slices.SortStableFunc(rs, func(a, b ranked) int { return cmp.Compare(b.count, a.count) })
+	modules := make([]string, len(rs))
+	for i, r := range rs {
+		modules[i] = r.module
+	}
+	return modules, nil
+}
+
+// loadCorpus loads the packages of each module in its own throwaway module, as loadTargets does for targets,
+// retrying each that fails.
+func loadCorpus(ctx context.Context, modules []string, opts iverson.LoadOptions) ([]*packages.Package, error) {
+	return loadEach(ctx, modules, func(module string) ([]*packages.Package, error) {
+		return retry(ctx, module, func() ([]*packages.Package, error) {
+			dir, pattern, err := moduleWorkspace(ctx, module)
+			if err != nil {
+				return nil, err
+			}
+			// the packages are loaded from the module cache, so nothing is needed from dir after
+			defer os.RemoveAll(dir)
+			if mod, url := moduleOrigin(ctx, dir, module); url != "" {
+				corpusRepos[mod] = url
+			}
+			return iverson.Packages(ctx, dir, pattern, opts)
+		})
+	})
+}
+
+// retry calls load until it succeeds, at most -retries times more after the first,
+// waiting -retry-wait before the first retry and twice as long before each after,
+// and returns the last result.
+func retry(ctx context.Context, module string, load func() ([]*packages.Package, error)) ([]*packages.Package, error) {
+	wait := retryWait
+	for attempt := 1; ; attempt++ {
+		ps, err := load()
+		if err == nil || attempt > loadRetries || ctx.Err() != nil {
+			return ps, err
+		}
+		slog.Warn("retrying module", "module", module, "attempt", attempt, "wait", wait, "err", err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+// duplicateFiles returns each file of ps with the same contents as a file of a module before it,
+// in the order ps were loaded, mapped to the first such file.
+// Files that cannot be read are never duplicates.
+func duplicateFiles(ps []*packages.Package) map[string]string {
+	type first struct{ file, module string }
+	seen := map[[sha256.Size]byte]first{}
+	dups := map[string]string{}
+	for _, pkg := range ps {
+		mod := "none"
+		if m := pkg.Module; m != nil {
+			mod = m.Path + "@" + m.Version
+		}
+		for _, f := range pkg.Syntax {
+			name := pkg.Fset.Position(f.Pos()).Filename
+			data, err := os.ReadFile(name)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(data)
+			if orig, ok := seen[sum]; !ok {
+				seen[sum] = first{name, mod}
+			} else if orig.module != mod && orig.file != name {
+				slog.Debug("duplicate file", "file", name, "of", orig.file)
+				dups[name] = orig.file
+			}
+		}
+	}
+	return dups
+}
+
+// dropDuplicates removes the findings in the files of dups from found
+// and returns the rest, with the counts of those removed by kind.
+func dropDuplicates(found []iverson.Finding, dups map[string]string) ([]iverson.Finding, map[string]int) {
+	var dropped map[string]int
+	found = slices.DeleteFunc(found, func(f iverson.Finding) bool {
+		if _, ok := dups[f.Pos.Filename]; !ok {
+			return false
+		}
+		if dropped == nil {
+			dropped = map[string]int{}
+		}
+		dropped[f.Kind]++
+		return true
+	})
+	return found, dropped
+}
+
+// moduleOrigin returns module as path@version, as go mod download resolves it in dir,
+// and the URL of the repository it came from, if the module proxy reports one.
+func moduleOrigin(ctx context.Context, dir, module string) (mod, url string) {
+	cmd := exec.CommandContext(ctx, "go", "mod", "download", "-json", module)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		slog.Debug("no origin for module", "module", module, "err", err)
+		return "", ""
+	}
+	var info struct {
+		Path, Version string
+		Origin        struct{ URL string }
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return "", ""
+	}
+	return info.Path + "@" + info.Version, info.Origin.URL
+}
+
+// repoOf returns the repository of the module of pkg:
+// the URL the module proxy reported for it,
+// or else the path of the module without any major version suffix,
+// cut to the first three elements on hosts with a repository at each, like github.com,
+// so that the modules of one repository have the same repository.
+func repoOf(pkg *packages.Package) string {
+	m := pkg.Module
+	if m == nil {
+		return "none"
+	}
+	if url := corpusRepos[m.Path+"@"+m.Version]; url != "" {
+		return url
+	}
+	repo, _, _ := module.SplitPathVersion(m.Path)
+	if elem := strings.Split(repo, "/"); len(elem) > 3 && slices.Contains(repoHosts, elem[0]) {
+		repo = strings.Join(elem[:3], "/")
+	}
+	return repo
+}
+
+// repoHosts are the hosts whose module paths name a repository in their first three elements.
+var repoHosts = []string{"github.com", "gitlab.com", "bitbucket.org", "codeberg.org"}
+
+// repoRollup adds up the packages analyzed in the modules of one repository.
+type repoRollup struct {
+	modules map[string]bool
+	counts  map[string]int
+	lines   int
+	pkgs    map[string]int // all implicit and explicit findings of each package
+}
+
+// repoRollups are the rollups of the corpus by repository.
+type repoRollups map[string]*repoRollup
+
+// add adds pkg with the counts of its findings to the rollup of its repository,
+// leaving out the lines of the files in dups.
+func (rs repoRollups) add(pkg *packages.Package, counts map[string]int, dups map[string]string) {
+	repo := repoOf(pkg)
+	r := rs[repo]
+	if r == nil {
+		r = &repoRollup{modules: map[string]bool{}, counts: map[string]int{}, pkgs: map[string]int{}}
+		rs[repo] = r
+	}
+	if m := pkg.Module; m != nil {
+		r.modules[m.Path+"@"+m.Version] = true
+	}
+	for kind, n := range counts {
+		r.counts[kind] += n
+	}
+	for _, f := range pkg.Syntax {
+		if tf := pkg.Fset.File(f.Pos()); dups[tf.Name()] == "" {
+			r.lines += tf.LineCount()
+		}
+	}
+	if n := counts[iverson.Implicit] + counts[iverson.Explicit]; n > 0 {
+		r.pkgs[pkg.PkgPath] += n
+	}
+}
+
+// topPackages is how many packages of each repository the rollup lists.
+const topPackages = 3
+
+// summaries returns the rollup of each repository, in order.
+func (rs repoRollups) summaries() []jsonRepo {
+	var repos []jsonRepo
+	for _, repo := range slices.Sorted(maps.Keys(rs)) {
+		r := rs[repo]
+		j := jsonRepo{Repo: repo, Modules: slices.Sorted(maps.Keys(r.modules)), Counts: r.counts, Lines: r.lines}
+		if r.lines > 0 {
+			j.Density = 1000 * float64(r.counts[iverson.Implicit]+r.counts[iverson.Explicit]) / float64(r.lines)
+		}
+		top := slices.SortedFunc(maps.Keys(r.pkgs), func(a, b string) int {
+			return cmp.Or(cmp.Compare(r.pkgs[b], r.pkgs[a]), cmp.Compare(a, b))
+		})
+		for _, path := range top[:min(len(top), topPackages)] {
+			j.Top = append(j.Top, jsonTop{path, r.pkgs[path]})
+		}
+		repos = append(repos, j)
+	}
+	return repos
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// csvHeader names the columns of csvRow.
+var csvHeader = []string{"package", "file", "line", "column", "kind", "form", "func", "id", "severity", "type", "where", "api", "usage", "arity", "composed", "alloc", "values", "rewrite", "cond", "ssa", "reach", "build", "go", "owner", "callee", "module", "lines", "chars", "proposed", "within"}
+
+func csvRow(pkg *packages.Package, f iverson.Finding) []string {
+	return []string{pkg.ID, f.Pos.Filename, strconv.Itoa(f.Pos.Line), strconv.Itoa(f.Pos.Column), f.Kind, f.Form, f.Func, f.ID, severity.of(f).String(), f.Type, f.Where, f.API, f.Usage, strconv.Itoa(f.Arity), strconv.Itoa(f.Composed), f.Alloc, f.Values, f.Rewrite, f.Cond, f.SSA, f.Reach, f.Build, f.GoVersion, f.Owner, f.Callee, f.Module, strconv.Itoa(f.Lines), strconv.Itoa(f.Chars), f.Proposed, f.Within}
+}
+
+// writeCSVTotals writes the counts of each kind of finding in each package to the file name,
+// with a final row for the total.
+func writeCSVTotals(name string, ps []jsonPackage, total map[string]int) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write(append([]string{"package", "path", "name"}, kinds...))
+	row := func(id, path, name string, counts map[string]int) {
+		r := []string{id, path, name}
+		for _, kind := range kinds {
+			r = append(r, strconv.Itoa(counts[kind]))
+		}
+		w.Write(r)
+	}
+	for _, p := range ps {
+		if p.ID != "" {
+			row(p.ID, p.Path, p.Name, p.Counts)
+		}
+	}
+	row("TOTAL", "", "", total)
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// depsWorkspace creates a throwaway module requiring exactly the modules that the go.mod file at name requires,
+// with its go.sum if there is one, and returns its directory,
+// the patterns matching every package in those modules,
+// and a func to remove it.
+// Replacements are kept, with local paths made relative to the original go.mod.
+func depsWorkspace(ctx context.Context, name string) (dir string, patterns []string, cleanup func(), err error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	orig, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(orig.Require) == 0 {
+		return "", nil, nil, fmt.Errorf("%s: no required modules", name)
+	}
+	origDir, err := filepath.Abs(filepath.Dir(name))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	mod := new(modfile.File)
+	if err := mod.AddModuleStmt("workspace"); err != nil {
+		return "", nil, nil, err
+	}
+	if orig.Go != nil {
+		if err := mod.AddGoStmt(orig.Go.Version); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	for _, r := range orig.Require {
+		mod.AddNewRequire(r.Mod.Path, r.Mod.Version, r.Indirect)
+		patterns = append(patterns, r.Mod.Path+"/...")
+	}
+	for _, r := range orig.Replace {
+		path := r.New.Path
+		if r.New.Version == "" && !filepath.IsAbs(path) {
+			path = filepath.Join(origDir, path)
+		}
+		if err := mod.AddReplace(r.Old.Path, r.Old.Version, path, r.New.Version); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	out, err := mod.Format()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	dir, err = os.MkdirTemp("", "issue61915-deps-")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), out, 0o644); err != nil {
+		return "", nil, nil, err
+	}
+	sum, err := os.ReadFile(filepath.Join(origDir, "go.sum"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		slog.Debug("no go.sum", "go.mod", name)
+	case err != nil:
+		return "", nil, nil, err
+	default:
+		if err := os.WriteFile(filepath.Join(dir, "go.sum"), sum, 0o644); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	if err := goCmd(ctx, dir, "mod", "download"); err != nil {
+		return "", nil, nil, err
+	}
+	return dir, patterns, func() { os.RemoveAll(dir) }, nil
+}
@@ -0,0 +1,720 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/constant"
+	goformat "go/format"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rogpeppe/go-internal/diff"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+	"github.com/jimmyfrasche/issue61915/syntax"
+)
+
+// fixHelper is the name of the helper that -fix adds to a package without one.
+const fixHelper = "b2i"
+
+// A fixer collects the edits that -fix makes to the files of each package to write them all at once.
+type fixer struct {
+	files   map[string]*fixFile
+	warned  map[string]bool   // the packages that cannot have a helper added, by import path, reported once each
+	added   map[string][]byte // the source of each file added for a helper
+	skipped map[string]string // why each file that cannot be fixed cannot be, reported once each
+	unfixed map[string]int    // the number of implicit findings not fixed for each reason, for -fix-audit
+}
+
+// fixFile is a file being fixed: its source as loaded and the edits to it.
+type fixFile struct {
+	src   []byte
+	edits []fixEdit
+}
+
+// fixEdit replaces the bytes of a source from start to end with text.
+type fixEdit struct {
+	start, end int
+	text       string
+}
+
+func newFixer() *fixer {
+	return &fixer{files: map[string]*fixFile{}, warned: map[string]bool{}, added: map[string][]byte{}, skipped: map[string]string{}, unfixed: map[string]int{}}
+}
+
+// add records the edits fixing the implicit ifs and switches in found that pkg has,
+// those setting a variable to 1 or 0, or to 0 or 1, by a bool without an init statement,
+// each into an assignment of a call of the helper of the package, like n = b2i(b),
+// and the increments by 1 under a bool, like if b { n++ }, each into n += b2i(b),
+// if neither the bool nor the number has side effects that the new order of evaluation could change.
+// Findings in packages outside the main module, in vendored, read-only, or unformatted files, or with comments that the fix would lose, are left alone,
+// and counted by why for -fix-audit.
+// The helper is added beside the first file fixed only once some edit calls it.
+func (x *fixer) add(pkg *packages.Package, found []iverson.Finding) error {
+	addTo := "" // the directory to add a helper to, if an edit calls one that is not declared
+	for _, f := range found {
+		if f.Kind != iverson.Implicit && f.Kind != iverson.Increment {
+			continue
+		}
+		if pkg.Module == nil || !pkg.Module.Main {
+			x.skip(f, "outside the main module")
+			continue
+		}
+		if reason := unfixableForm(f); reason != "" {
+			x.skip(f, reason)
+			continue
+		}
+		file := fileOf(pkg, f.Pos.Filename)
+		if file == nil {
+			x.skip(f, "file not loaded")
+			continue
+		}
+		if !x.fixable(f.Pos.Filename) {
+			x.skip(f, x.skipped[f.Pos.Filename])
+			continue
+		}
+		if lineDirectives(file) {
+			x.skip(f, "line directives")
+			continue
+		}
+		n, lhs, cond, tok, returns := fixSite(pkg, file, f.Pos.Offset, f.Kind)
+		// the position of a finding may name another file by a line directive in its own
+		if n == nil || pkg.Fset.Position(n.Pos()) != f.Pos {
+			x.skip(f, "branches differ")
+			continue
+		}
+		// n += b2i(b) may evaluate n and b in either order, unlike if b { n++ }
+		if tok != token.ASSIGN && (sideEffects(pkg.TypesInfo, cond) || sideEffects(pkg.TypesInfo, lhs)) {
+			x.skip(f, "side effects")
+			continue
+		}
+		if commented(pkg.Fset, file, n) {
+			x.skip(f, "comments")
+			continue
+		}
+		helper, declared, reason := x.helper(pkg, f.Pos.Filename)
+		if helper == "" {
+			x.skip(f, reason)
+			continue
+		}
+		if _, obj := pkg.Types.Scope().Innermost(n.Pos()).LookupParent(helper, n.Pos()); obj != pkg.Types.Scope().Lookup(helper) {
+			x.skip(f, "helper shadowed")
+			continue
+		}
+		ff, err := x.file(f.Pos.Filename)
+		if err != nil {
+			return err
+		}
+		text := func(n ast.Node) string {
+			return string(ff.src[fileOffset(pkg.Fset, n.Pos()):fileOffset(pkg.Fset, n.End())])
+		}
+		if f.Rewrite == "invert" {
+			if u, ok := ast.Unparen(cond).(*ast.UnaryExpr); ok && u.Op == token.NOT {
+				cond = u.X
+			} else {
+				x := ast.Unparen(cond)
+				if _, ok := x.(*ast.BinaryExpr); ok {
+					x = &ast.ParenExpr{X: x}
+				}
+				cond = &ast.UnaryExpr{Op: token.NOT, X: x}
+			}
+		}
+		call := helper + "(" + fixText(text, cond) + ")"
+		if typ := pkg.TypesInfo.TypeOf(lhs); !types.Identical(typ, types.Typ[types.Int]) {
+			name, ok := typeName(pkg, file, typ)
+			if !ok {
+				x.skip(f, "type not named in file")
+				continue
+			}
+			call = name + "(" + call + ")"
+		}
+		start, end := fileOffset(pkg.Fset, n.Pos()), fileOffset(pkg.Fset, n.End())
+		fix := text(lhs) + " " + tok.String() + " " + call
+		if returns {
+			fix += "\nreturn"
+		}
+		ff.edits = append(ff.edits, fixEdit{start, end, fix})
+		if !declared && addTo == "" {
+			addTo = filepath.Dir(f.Pos.Filename)
+		}
+	}
+	if addTo != "" {
+		return x.addHelper(pkg, addTo)
+	}
+	return nil
+}
+
+// skip notes that the implicit or increment finding f is not fixed, and why.
+func (x *fixer) skip(f iverson.Finding, reason string) {
+	slog.Debug("not fixing", "pos", f.Pos, "reason", reason)
+	x.unfixed[reason]++
+}
+
+// unfixableForm returns why the implicit or increment finding f cannot be fixed by its form and rewrite alone, or "" if it may be.
+func unfixableForm(f iverson.Finding) string {
+	switch {
+	case f.Form != "if" && f.Form != "switch" && (f.Kind != iverson.Increment || f.Form != "compound"):
+		return f.Form + " form"
+	case f.Rewrite == "temporary":
+		return "init statement"
+	case f.Rewrite == "scale":
+		return "scaled"
+	case f.Rewrite != "direct" && f.Rewrite != "invert":
+		return "not 0 or 1"
+	}
+	return ""
+}
+
+// fixable reports whether the file named name can be fixed, reporting why not the first time it cannot:
+// a file in a vendor directory belongs to another module, even if the main module vendors it,
+// a read-only file is meant to be left alone,
+// and a file that gofmt would change is too, so that formatting the fixed file changes only what the edits do.
+func (x *fixer) fixable(name string) bool {
+	if x.skipped[name] != "" {
+		return false
+	}
+	reason := ""
+	if slices.Contains(strings.Split(filepath.ToSlash(name), "/"), "vendor") {
+		reason = "vendored"
+	} else if fi, err := os.Stat(name); err != nil {
+		reason = err.Error()
+	} else if fi.Mode().Perm()&0o222 == 0 {
+		reason = "read-only"
+	} else if ff, err := x.file(name); err != nil {
+		reason = err.Error()
+	} else if src, err := goformat.Source(ff.src); err != nil || !bytes.Equal(src, ff.src) {
+		reason = "not gofmt-clean"
+	}
+	if reason == "" {
+		return true
+	}
+	slog.Warn("not fixing file", "file", name, "reason", reason)
+	x.skipped[name] = reason
+	return false
+}
+
+// fixText returns the source of x, which may be a negation built around nodes of the source.
+func fixText(text func(ast.Node) string, x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT && !x.Pos().IsValid() {
+			return "!" + fixText(text, x.X)
+		}
+	case *ast.ParenExpr:
+		if !x.Lparen.IsValid() {
+			return "(" + fixText(text, x.X) + ")"
+		}
+	}
+	return text(x)
+}
+
+// fileOffset returns the offset of pos in the source of its file, regardless of line directives.
+func fileOffset(fset *token.FileSet, pos token.Pos) int {
+	return fset.PositionFor(pos, false).Offset
+}
+
+// lineDirectives reports whether file has //line or /*line directives,
+// as cgo and goyacc output does, after which positions no longer name the source being edited.
+func lineDirectives(file *ast.File) bool {
+	for _, cg := range file.Comments {
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "//line ") || strings.HasPrefix(c.Text, "/*line ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileOf returns the syntax of the file of pkg named name, by its name on disk, if it has it.
+func fileOf(pkg *packages.Package, name string) *ast.File {
+	for _, file := range pkg.Syntax {
+		if pkg.Fset.PositionFor(file.FileStart, false).Filename == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// fixSite returns the if or switch statement starting at the offset at in file for a finding of kind,
+// with the variable it sets, the bool that sets it to 1 or increments it, the assignment token of the fix,
+// and whether its branches return after setting it, as when setting a named result.
+// Branches setting different variables, or where only one returns, are not fixed,
+// nor are increments by anything but 1.
+func fixSite(pkg *packages.Package, file *ast.File, at int, kind string) (n ast.Stmt, lhs, cond ast.Expr, tok token.Token, returns bool) {
+	ast.Inspect(file, func(node ast.Node) bool {
+		if n != nil || node == nil || fileOffset(pkg.Fset, node.Pos()) > at || fileOffset(pkg.Fset, node.End()) <= at {
+			return n == nil
+		}
+		if fileOffset(pkg.Fset, node.Pos()) != at {
+			return true
+		}
+		switch node := node.(type) {
+		case *ast.IfStmt:
+			if kind == iverson.Increment {
+				if x, op, ok := incrementByOne(pkg.TypesInfo, node); ok {
+					n, lhs, cond, tok = node, x, node.Cond, op
+				}
+			} else if node.Init == nil && iverson.PotentialIversonIf(pkg, node) && syntax.SameBranches(node.Body, node.Else.(*ast.BlockStmt)) {
+				n, lhs, cond, tok, returns = node, syntax.BranchAssign(node.Body).Lhs[0], node.Cond, token.ASSIGN, syntax.BranchReturns(node.Body)
+			}
+		case *ast.SwitchStmt:
+			if x, then, els, ok := iverson.SwitchBranches(pkg, node); ok && kind == iverson.Implicit && node.Init == nil && syntax.SameBranches(then, els) {
+				n, lhs, cond, tok, returns = node, syntax.BranchAssign(then).Lhs[0], x, token.ASSIGN, syntax.BranchReturns(then)
+			}
+		}
+		return true
+	})
+	return n, lhs, cond, tok, returns
+}
+
+// incrementByOne returns the number that n increments or decrements by 1 if its bool is true,
+// as in if b { n++ } or if b { n -= 1 } without an init statement or else,
+// and the assignment token of the fix, += or -=.
+func incrementByOne(info *types.Info, n *ast.IfStmt) (ast.Expr, token.Token, bool) {
+	if n.Init != nil || n.Else != nil || len(n.Body.List) != 1 {
+		return nil, token.ILLEGAL, false
+	}
+	switch s := n.Body.List[0].(type) {
+	case *ast.IncDecStmt:
+		if s.Tok == token.INC {
+			return s.X, token.ADD_ASSIGN, true
+		}
+		return s.X, token.SUB_ASSIGN, true
+	case *ast.AssignStmt:
+		if (s.Tok != token.ADD_ASSIGN && s.Tok != token.SUB_ASSIGN) || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			break
+		}
+		if v := constant.ToInt(info.Types[s.Rhs[0]].Value); v.Kind() == constant.Int && constant.Compare(v, token.EQL, constant.MakeInt64(1)) {
+			return s.Lhs[0], s.Tok, true
+		}
+	}
+	return nil, token.ILLEGAL, false
+}
+
+// sideEffects reports whether evaluating x could change anything or depend on the order it is evaluated in:
+// whether it calls anything but a conversion or a builtin like len, or receives from a channel.
+func sideEffects(info *types.Info, x ast.Expr) bool {
+	effects := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+				break
+			}
+			if id, ok := ast.Unparen(n.Fun).(*ast.Ident); ok {
+				if b, ok := info.Uses[id].(*types.Builtin); ok {
+					switch b.Name() {
+					case "len", "cap", "min", "max", "real", "imag", "complex":
+						return true
+					}
+				}
+			}
+			effects = true
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				effects = true
+			}
+		case *ast.FuncLit:
+			// a func literal is not run by being evaluated
+			return false
+		}
+		return !effects
+	})
+	return effects
+}
+
+// commented reports whether any comment of file is within n.
+func commented(fset *token.FileSet, file *ast.File, n ast.Node) bool {
+	for _, cg := range file.Comments {
+		if cg.Pos() >= n.Pos() && cg.End() <= n.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName returns the name of typ in file, qualified by the name the file imports its package as if it is another package.
+func typeName(pkg *packages.Package, file *ast.File, typ types.Type) (string, bool) {
+	ok := true
+	name := types.TypeString(typ, func(p *types.Package) string {
+		if p == pkg.Types {
+			return ""
+		}
+		for _, imp := range file.Imports {
+			if strings.Trim(imp.Path.Value, `"`) != p.Path() {
+				continue
+			}
+			if imp.Name == nil {
+				return p.Name()
+			}
+			if imp.Name.Name != "_" && imp.Name.Name != "." {
+				return imp.Name.Name
+			}
+		}
+		ok = false
+		return p.Name()
+	})
+	return name, ok
+}
+
+// helper returns the name of the helper for the file named file of pkg to call,
+// a func b2i(b bool) int or one like it already declared where the file can see it,
+// and whether it is declared, rather than to be added.
+// It returns "" and why if the file cannot have one, as when something else is named b2i already.
+func (x *fixer) helper(pkg *packages.Package, file string) (name string, declared bool, reason string) {
+	if name := existingHelper(pkg, file); name != "" {
+		return name, true, ""
+	}
+	if pkg.Types.Scope().Lookup(fixHelper) == nil {
+		return fixHelper, false, ""
+	}
+	if existingHelper(pkg, "") != "" {
+		return "", false, "helper not visible from file"
+	}
+	if !x.warned[pkg.PkgPath] {
+		slog.Warn("not fixing package", "pkg", pkg.ID, "reason", fixHelper+" is declared but is not a helper")
+		x.warned[pkg.PkgPath] = true
+	}
+	return "", false, fixHelper + " is declared but is not a helper"
+}
+
+// existingHelper returns the name of a func of pkg named like a helper, as FindProbableHelpers would report them,
+// taking exactly a bool and returning an int,
+// whose body, if pkg has it, only returns 1 if the bool is true and 0 if it is false,
+// and which the file named file can call, as by visible, unless file is "".
+func existingHelper(pkg *packages.Package, file string) string {
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !iverson.IsHelperName(name) {
+			continue
+		}
+		sig := fn.Signature()
+		if sig.TypeParams() != nil || sig.Params().Len() != 1 || sig.Results().Len() != 1 ||
+			!types.Identical(sig.Params().At(0).Type(), types.Typ[types.Bool]) || !types.Identical(sig.Results().At(0).Type(), types.Typ[types.Int]) {
+			continue
+		}
+		if decl := funcDecl(pkg, fn); decl != nil && !helperBody(pkg.TypesInfo, decl) {
+			continue
+		}
+		if file != "" && !visible(pkg, pkg.Fset.PositionFor(fn.Pos(), false).Filename, file) {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// visible reports whether what the file named decl of pkg declares can be used from the file named file in every build of it:
+// decl is file, or it is not a _test.go file unless file is one, and it has no build constraints,
+// neither a //go:build line nor a name ending in a GOOS or GOARCH.
+func visible(pkg *packages.Package, decl, file string) bool {
+	if decl == file {
+		return true
+	}
+	if strings.HasSuffix(decl, "_test.go") && !strings.HasSuffix(file, "_test.go") {
+		return false
+	}
+	if f := fileOf(pkg, decl); f != nil {
+		for _, cg := range f.Comments {
+			if cg.Pos() >= f.Package {
+				break
+			}
+			for _, c := range cg.List {
+				if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+					return false
+				}
+			}
+		}
+	}
+	// a context matching no GOOS or GOARCH rejects only the names ending in one
+	ctxt := build.Context{GOOS: "none", GOARCH: "none", Compiler: "gc", OpenFile: func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("package p\n")), nil
+	}}
+	ok, err := ctxt.MatchFile(filepath.Dir(decl), filepath.Base(decl))
+	return err == nil && ok
+}
+
+// funcDecl returns the declaration of fn, if it is in the syntax of pkg.
+func funcDecl(pkg *packages.Package, fn *types.Func) *ast.FuncDecl {
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && pkg.TypesInfo.Defs[decl.Name] == fn {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// helperBody reports whether the body of decl is if b { return 1 }; return 0 or if b { return 1 } else { return 0 },
+// where b is its only parameter.
+func helperBody(info *types.Info, decl *ast.FuncDecl) bool {
+	params := decl.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) != 1 || decl.Body == nil {
+		return false
+	}
+	param := info.Defs[params[0].Names[0]]
+	// returns reports whether list is only a return of v
+	returns := func(list []ast.Stmt, v int64) bool {
+		if len(list) != 1 {
+			return false
+		}
+		ret, ok := list[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			return false
+		}
+		val := constant.ToInt(info.Types[ret.Results[0]].Value)
+		if val == nil || val.Kind() != constant.Int {
+			return false
+		}
+		n, exact := constant.Int64Val(val)
+		return exact && n == v
+	}
+	list := decl.Body.List
+	if len(list) == 0 {
+		return false
+	}
+	ifStmt, ok := list[0].(*ast.IfStmt)
+	if !ok || ifStmt.Init != nil || !returns(ifStmt.Body.List, 1) {
+		return false
+	}
+	if id, ok := ast.Unparen(ifStmt.Cond).(*ast.Ident); !ok || info.Uses[id] != param {
+		return false
+	}
+	switch els := ifStmt.Else.(type) {
+	case *ast.BlockStmt:
+		return len(list) == 1 && returns(els.List, 0)
+	case nil:
+		return len(list) == 2 && returns(list[1:], 0)
+	}
+	return false
+}
+
+// addHelper adds a file declaring the helper for pkg to dir, unless it is added already,
+// named for the helper like b2i.go, or b2i_test.go for an external test package.
+func (x *fixer) addHelper(pkg *packages.Package, dir string) error {
+	name := fixHelper + ".go"
+	if strings.HasSuffix(pkg.Name, "_test") {
+		name = fixHelper + "_test.go"
+	}
+	name = filepath.Join(dir, name)
+	if _, ok := x.added[name]; ok {
+		return nil
+	}
+	if _, err := os.Stat(name); err == nil {
+		return fmt.Errorf("cannot add %s for the -fix of %s: it exists", name, pkg.ID)
+	}
+	src := fmt.Sprintf(`package %s
+
+// %[2]s returns 1 if b is true and 0 if it is false.
+func %[2]s(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+`, pkg.Name, fixHelper)
+	x.added[name] = []byte(src)
+	return nil
+}
+
+// file returns the file named name to record edits to, reading its source the first time.
+func (x *fixer) file(name string) (*fixFile, error) {
+	if ff, ok := x.files[name]; ok {
+		return ff, nil
+	}
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	ff := &fixFile{src: src}
+	x.files[name] = ff
+	return ff, nil
+}
+
+// A fixedFile is the source of a file before and after -fix, which is nil before for an added file.
+type fixedFile struct {
+	name     string
+	old, new []byte
+	fixes    int
+}
+
+// fixed returns the files as they are after the edits, and the files added, formatted, by name.
+func (x *fixer) fixed() ([]fixedFile, error) {
+	var out []fixedFile
+	for name, ff := range x.files {
+		if len(ff.edits) == 0 {
+			continue
+		}
+		edits := slices.SortedFunc(slices.Values(ff.edits), func(a, b fixEdit) int {
+			return cmp.Compare(a.start, b.start)
+		})
+		var b bytes.Buffer
+		last, fixes := 0, 0
+		for _, e := range edits {
+			if e.start < last {
+				// nested in the last edit, which replaces it
+				continue
+			}
+			b.Write(ff.src[last:e.start])
+			b.WriteString(e.text)
+			last = e.end
+			fixes++
+		}
+		b.Write(ff.src[last:])
+		src, err := goformat.Source(b.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: formatting fixed source: %w", name, err)
+		}
+		out = append(out, fixedFile{name, ff.src, src, fixes})
+	}
+	for name, src := range x.added {
+		out = append(out, fixedFile{name: name, new: src})
+	}
+	slices.SortFunc(out, func(a, b fixedFile) int {
+		return cmp.Compare(a.name, b.name)
+	})
+	return out, nil
+}
+
+// A fixAudit counts the implicit findings that -fix would fix and those that need attention, by why, for -fix-audit.
+type fixAudit struct {
+	Fixable int            `json:"fixable"`
+	Unfixed map[string]int `json:"unfixed,omitempty"`
+}
+
+// audit returns the counts of the findings fixed by the edits and those not fixed, without writing anything.
+// An edit nested in another that replaces it counts as not fixed.
+func (x *fixer) audit() (*fixAudit, error) {
+	files, err := x.fixed()
+	if err != nil {
+		return nil, err
+	}
+	a := &fixAudit{Unfixed: maps.Clone(x.unfixed)}
+	edits := 0
+	for _, ff := range x.files {
+		edits += len(ff.edits)
+	}
+	for _, f := range files {
+		a.Fixable += f.fixes
+	}
+	if nested := edits - a.Fixable; nested > 0 {
+		a.Unfixed["nested in another fix"] = nested
+	}
+	return a, nil
+}
+
+// diff writes the edits to w as a unified diff of each file fixed or added,
+// named relative to the working directory if under it and prefixed by a/ and b/, as git apply expects.
+func (x *fixer) diff(w io.Writer) error {
+	files, err := x.fixed()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := artifactURI(f.name)
+		old := "a/" + name
+		if f.old == nil {
+			old = "/dev/null"
+		}
+		if _, err := w.Write(diff.Diff(old, f.old, "b/"+name, f.new)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes the fixed and added files.
+// Each is checked first, and then written to a temporary file beside it that is renamed into place,
+// so that a file that cannot be written leaves every file as it was, rather than the tree half fixed.
+func (x *fixer) write() error {
+	files, err := x.fixed()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writable(f); err != nil {
+			return err
+		}
+	}
+	temps := make([]string, len(files))
+	defer func() {
+		for _, name := range temps {
+			if name != "" {
+				os.Remove(name)
+			}
+		}
+	}()
+	for i, f := range files {
+		mode := os.FileMode(0o644)
+		if fi, err := os.Stat(f.name); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if f.old == nil {
+			// an added file may be in a new package
+			if err := os.MkdirAll(filepath.Dir(f.name), 0o755); err != nil {
+				return err
+			}
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(f.name), "."+filepath.Base(f.name)+".*")
+		if err != nil {
+			return err
+		}
+		temps[i] = tmp.Name()
+		_, err = tmp.Write(f.new)
+		if err == nil {
+			err = tmp.Chmod(mode)
+		}
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i, f := range files {
+		if err := os.Rename(temps[i], f.name); err != nil {
+			return err
+		}
+		temps[i] = ""
+		if f.old == nil {
+			slog.Info("added helper", "file", f.name)
+		} else {
+			slog.Info("fixed file", "file", f.name, "fixes", f.fixes)
+		}
+	}
+	return nil
+}
+
+// writable returns an error if f cannot be written: a fixed file that is read-only or gone, or an added file that exists.
+// Read-only files are skipped by fixable, so this only fails if one changes during the run.
+func writable(f fixedFile) error {
+	fi, err := os.Stat(f.name)
+	switch {
+	case f.old == nil && err == nil:
+		return fmt.Errorf("cannot add %s: it exists", f.name)
+	case f.old == nil && errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case fi.Mode().Perm()&0o222 == 0:
+		return fmt.Errorf("cannot fix %s: it is read-only", f.name)
+	}
+	return nil
+}
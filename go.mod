@@ -1,8 +1,14 @@
 module github.com/jimmyfrasche/issue61915
 
-go 1.21.0
+go 1.25.0
 
 require (
-	golang.org/x/mod v0.14.0 // indirect
-	golang.org/x/tools v0.16.1 // indirect
+	github.com/rogpeppe/go-internal v1.16.0
+	golang.org/x/mod v0.35.0
+	golang.org/x/tools v0.44.0
+)
+
+require (
+	golang.org/x/sync v0.20.0 // indirect
+	golang.org/x/sys v0.43.0 // indirect
 )
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"cmp"
+
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"html/template"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// snippetContext is the number of lines of source shown before and after each finding in the -html report.
+const snippetContext = 2
+
+// An htmlReport collects the findings in each package for -html.
+type htmlReport struct {
+	Packages []htmlPackage
+	Total    []int // by kinds
+	sources  map[string][]string
+}
+
+type htmlPackage struct {
+	Path, Name string
+	Counts     []int // by kinds
+	Files      []htmlFile
+}
+
+type htmlFile struct {
+	File     string
+	Name     string // relative to the working directory if under it
+	Findings []htmlFinding
+}
+
+type htmlFinding struct {
+	Line, Column int
+	Kind, Form   string
+	Func, ID     string
+	Snippet      []htmlLine
+	Proposed     string // Finding.Proposed
+}
+
+type htmlLine struct {
+	N    int
+	Hit  bool
+	Code template.HTML
+}
+
+// add records the findings in pkg, grouped by file, if there are any.
+func (r *htmlReport) add(pkg *packages.Package, found []iverson.Finding, counts map[string]int) {
+	if len(found) == 0 {
+		return
+	}
+	p := htmlPackage{Path: pkg.PkgPath, Name: pkg.Name}
+	for _, kind := range kinds {
+		p.Counts = append(p.Counts, counts[kind])
+	}
+	found = slices.SortedFunc(slices.Values(found), func(a, b iverson.Finding) int {
+		return cmp.Or(strings.Compare(a.Pos.Filename, b.Pos.Filename), cmp.Compare(a.Pos.Offset, b.Pos.Offset))
+	})
+	for _, f := range found {
+		if len(p.Files) == 0 || p.Files[len(p.Files)-1].File != f.Pos.Filename {
+			p.Files = append(p.Files, htmlFile{File: f.Pos.Filename, Name: artifactURI(f.Pos.Filename)})
+		}
+		file := &p.Files[len(p.Files)-1]
+		file.Findings = append(file.Findings, htmlFinding{
+			Line:     f.Pos.Line,
+			Column:   f.Pos.Column,
+			Kind:     f.Kind,
+			Form:     f.Form,
+			Func:     f.Func,
+			ID:       f.ID,
+			Snippet:  r.snippet(f.Pos),
+			Proposed: f.Proposed,
+		})
+	}
+	r.Packages = append(r.Packages, p)
+}
+
+// snippet returns the highlighted lines of source around pos,
+// or nothing if the file cannot be read.
+func (r *htmlReport) snippet(pos token.Position) []htmlLine {
+	if r.sources == nil {
+		r.sources = map[string][]string{}
+	}
+	lines, ok := r.sources[pos.Filename]
+	if !ok {
+		data, err := os.ReadFile(pos.Filename)
+		if err == nil {
+			lines = strings.Split(string(data), "\n")
+		}
+		r.sources[pos.Filename] = lines
+	}
+	first, last := max(pos.Line-snippetContext, 1), min(pos.Line+snippetContext, len(lines))
+	var out []htmlLine
+	for n := first; n <= last; n++ {
+		out = append(out, htmlLine{N: n, Hit: n == pos.Line, Code: highlight(lines[n-1])})
+	}
+	return out
+}
+
+// highlight returns the line of Go source as HTML with spans classed by token:
+// keyword, string, number, or comment.
+// A line in the middle of a multiline comment or raw string is highlighted as best it can be.
+func highlight(line string) template.HTML {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	src := []byte(line)
+	s.Init(fset.AddFile("", -1, len(src)), src, func(token.Position, string) {}, scanner.ScanComments)
+	var buf bytes.Buffer
+	done := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		var class string
+		switch {
+		case tok.IsKeyword():
+			class = "kw"
+		case tok == token.STRING, tok == token.CHAR:
+			class = "str"
+		case tok == token.INT, tok == token.FLOAT, tok == token.IMAG:
+			class = "num"
+		case tok == token.COMMENT:
+			class = "com"
+		default:
+			continue
+		}
+		start := int(pos) - 1
+		end := start + len(lit)
+		if start < done || end > len(line) {
+			continue
+		}
+		template.HTMLEscape(&buf, src[done:start])
+		buf.WriteString(`<span class="` + class + `">`)
+		template.HTMLEscape(&buf, src[start:end])
+		buf.WriteString(`</span>`)
+		done = end
+	}
+	template.HTMLEscape(&buf, src[done:])
+	return template.HTML(buf.String())
+}
+
+// write writes r as a single HTML file, name, with the total counts of each kind.
+func (r *htmlReport) write(name string, total map[string]int) error {
+	r.Total = nil
+	for _, kind := range kinds {
+		r.Total = append(r.Total, total[kind])
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := htmlTemplate.Execute(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"kinds": func() []string { return kinds },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>issue61915 report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+details { margin: 0.3em 0 0.3em 1em; }
+summary { cursor: pointer; }
+pre { background: #f6f6f6; margin: 0.3em 0 0.8em 1em; padding: 0.4em; }
+pre .n { color: #999; user-select: none; }
+pre .hit { background: #fff3b0; }
+pre.proposed { background: #eef6ee; }
+.kw { color: #00c; } .str { color: #080; } .num { color: #a50; } .com { color: #777; font-style: italic; }
+.hidden { display: none; }
+</style>
+</head>
+<body>
+<h1>Bool to number conversions</h1>
+<p>Show
+{{range kinds}}<label><input type="checkbox" class="kind" value="{{.}}" checked> {{.}}</label>
+{{end}}</p>
+<table>
+<tr><th>package</th>{{range kinds}}<th>{{.}}</th>{{end}}</tr>
+{{range $i, $p := .Packages}}<tr><td><a href="#pkg{{$i}}">{{.Path}}</a></td>{{range .Counts}}<td>{{.}}</td>{{end}}</tr>
+{{end}}<tr><th>TOTAL</th>{{range .Total}}<th>{{.}}</th>{{end}}</tr>
+</table>
+{{range $i, $p := .Packages}}
+<h2 id="pkg{{$i}}">{{.Path}} ({{.Name}})</h2>
+{{range .Files}}<details open>
+<summary>{{.Name}}: {{len .Findings}}</summary>
+{{range .Findings}}<div class="finding" data-kind="{{.Kind}}">
+<details open>
+<summary>{{.Line}}:{{.Column}} {{.Kind}}{{with .Form}} {{.}}{{end}}{{with .Func}} in {{.}}{{end}} <code>{{.ID}}</code></summary>
+<pre>{{range .Snippet}}<span class="n">{{printf "%5d" .N}}</span> {{if .Hit}}<span class="hit">{{.Code}}</span>{{else}}{{.Code}}{{end}}
+{{end}}</pre>
+{{with .Proposed}}<pre class="proposed"><span class="n">proposed</span> {{.}}</pre>
+{{end}}</details>
+</div>
+{{end}}</details>
+{{end}}{{end}}
+<script>
+for (const box of document.querySelectorAll("input.kind")) {
+	box.addEventListener("change", () => {
+		for (const f of document.querySelectorAll(".finding[data-kind='" + box.value + "']")) {
+			f.classList.toggle("hidden", !box.checked);
+		}
+	});
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,69 @@
+package iverson
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports each bool to number conversion that Find does, one diagnostic per finding,
+// with the kind of finding as its category.
+// It has no notion of the aggregate counts, which only the CLI reports.
+// The registered transforms apply to its findings as to those of the CLI.
+var Analyzer = &analysis.Analyzer{
+	Name: "issue61915",
+	Doc: `report bool to number conversions
+
+Report if-else statements setting a number to 0 or 1 by a bool,
+calls of funcs from bool to number, and reads of maps from bool to number,
+the idioms a builtin conversion as proposed in golang.org/issue/61915 would replace.`,
+	URL: "https://github.com/jimmyfrasche/issue61915",
+	Run: run,
+}
+
+var (
+	analyzerOpts      Options
+	analyzerGenerated bool
+)
+
+func init() {
+	Analyzer.Flags.BoolVar(&analyzerOpts.Imported, "imported", false, "also report calls of bracket funcs and methods from other packages, like pkg.Btoi(b)")
+	Analyzer.Flags.BoolVar(&analyzerOpts.Returns, "returns", false, "also report ifs returning a number by a bool, like the bodies of bracket funcs")
+	Analyzer.Flags.BoolVar(&analyzerGenerated, "include-generated", false, "also report findings in generated files, with a // Code generated ... DO NOT EDIT. comment")
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	found := analyze(pass.Fset, pass.Files, pass.TypesInfo, pass.Pkg, analyzerOpts)
+	if !analyzerGenerated {
+		found, _ = DropGenerated(pass.Files, pass.Fset, found)
+	}
+	found = ApplyTransforms(found)
+	for _, f := range found {
+		pass.Report(analysis.Diagnostic{
+			Pos:      findingPos(pass.Fset, pass.Files, f.Pos),
+			Category: f.Kind,
+			Message:  f.Message(),
+		})
+	}
+	return nil, nil
+}
+
+// Message describes f by its kind and form, as the diagnostics of Analyzer do.
+func (f Finding) Message() string {
+	msg := f.Kind + " bool to number conversion"
+	if f.Form != "" {
+		msg += " (" + f.Form + ")"
+	}
+	return msg
+}
+
+// findingPos returns the token.Pos of pos in files.
+func findingPos(fset *token.FileSet, files []*ast.File, pos token.Position) token.Pos {
+	for _, file := range files {
+		if tf := fset.File(file.Pos()); tf != nil && tf.Name() == pos.Filename {
+			return tf.Pos(pos.Offset)
+		}
+	}
+	return token.NoPos
+}
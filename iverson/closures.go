@@ -0,0 +1,108 @@
+package iverson
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// closureNames names each func literal in files like the compiler does, F.func1, F.func1.1, and so on,
+// numbering those outside any function func1, func2, and so on across the files.
+func closureNames(files []*ast.File) map[*ast.FuncLit]string {
+	names := map[*ast.FuncLit]string{}
+	lits := map[string]int{} // number of closures seen so far in each function
+	var walk func(n ast.Node, fn string, nested bool)
+	walk = func(n ast.Node, fn string, nested bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			lit, ok := n.(*ast.FuncLit)
+			if !ok {
+				return true
+			}
+			lits[fn]++
+			name := fmt.Sprintf("%s.func%d", fn, lits[fn])
+			switch {
+			case fn == "":
+				name = fmt.Sprintf("func%d", lits[fn])
+			case nested:
+				name = fmt.Sprintf("%s.%d", fn, lits[fn])
+			}
+			names[lit] = name
+			walk(lit.Body, name, true)
+			return false
+		})
+	}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			fn := ""
+			if decl, ok := decl.(*ast.FuncDecl); ok {
+				fn = funcName(decl)
+			}
+			walk(decl, fn, false)
+		}
+	}
+	return names
+}
+
+// boundClosures returns the variables bound to a bracket func literal in files, as by toInt := func(b bool) int { ... },
+// and never assigned anything else or having their address taken, so that the calls of each are calls of the literal.
+func boundClosures(info *types.Info, files []*ast.File) map[types.Object]*ast.FuncLit {
+	bound := map[types.Object]*ast.FuncLit{}
+	other := map[types.Object]bool{} // variables assigned something else too
+	bind := func(id *ast.Ident, x ast.Expr) {
+		v, ok := info.ObjectOf(id).(*types.Var)
+		if !ok || v.IsField() {
+			return
+		}
+		if lit, ok := ast.Unparen(x).(*ast.FuncLit); ok && bound[v] == nil && IsBracketFunc(info.TypeOf(lit)) {
+			bound[v] = lit
+			return
+		}
+		other[v] = true
+	}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					id, ok := ast.Unparen(lhs).(*ast.Ident)
+					if !ok {
+						continue
+					}
+					var rhs ast.Expr // nil for a compound assignment or a call returning several values
+					if (n.Tok == token.ASSIGN || n.Tok == token.DEFINE) && len(n.Lhs) == len(n.Rhs) {
+						rhs = n.Rhs[i]
+					}
+					bind(id, rhs)
+				}
+			case *ast.ValueSpec:
+				// a var without values starts out nil, which calling would panic on
+				if len(n.Values) == 0 {
+					break
+				}
+				for i, id := range n.Names {
+					var rhs ast.Expr
+					if len(n.Names) == len(n.Values) {
+						rhs = n.Values[i]
+					}
+					bind(id, rhs)
+				}
+			case *ast.RangeStmt:
+				for _, x := range []ast.Expr{n.Key, n.Value} {
+					if id, ok := x.(*ast.Ident); ok {
+						bind(id, nil)
+					}
+				}
+			case *ast.UnaryExpr:
+				if id, ok := ast.Unparen(n.X).(*ast.Ident); ok && n.Op == token.AND {
+					bind(id, nil)
+				}
+			}
+			return true
+		})
+	}
+	for v := range other {
+		delete(bound, v)
+	}
+	return bound
+}
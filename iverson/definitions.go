@@ -0,0 +1,65 @@
+package iverson
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FindDefinitions reports the bracket funcs and methods declared in pkg, those whose calls are explicit findings,
+// like func btoi(b bool) int or func (Flags) count(b bool) uint8,
+// and the bracket func literals, like toInt := func(b bool) int { ... },
+// so that how many helpers a package defines can be counted apart from how often they are called.
+func FindDefinitions(pkg *packages.Package) []Finding {
+	if pkg.TypesInfo == nil {
+		return nil
+	}
+	closures := closureNames(pkg.Syntax)
+	var found []Finding
+	for _, file := range pkg.Syntax {
+		define := func(name, form string, sig *types.Signature, pos, end token.Pos) {
+			found = append(found, Finding{
+				ID:        contentID(pkg.PkgPath, name, Definition, sig.String()),
+				Content:   contentID(name, Definition, sig.String()),
+				Shape:     contentID(Definition, sig.String()),
+				Pos:       pkg.Fset.Position(pos),
+				End:       pkg.Fset.Position(end),
+				Kind:      Definition,
+				Form:      form,
+				Func:      name,
+				Type:      numericKind(sig.Results().At(0).Type()),
+				Build:     buildConstraint(file),
+				GoVersion: goVersion(pkg, file),
+			})
+			f := &found[len(found)-1]
+			f.Lines, f.Chars = span(f.Pos, f.End)
+		}
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok {
+				if fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func); ok {
+					// a method is called as its method value, without the receiver
+					sig := fn.Signature()
+					noRecv := types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+					if IsBracketFunc(noRecv) {
+						define(funcName(decl), "func", sig, decl.Name.Pos(), decl.Name.End())
+					}
+				}
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				lit, ok := n.(*ast.FuncLit)
+				if !ok {
+					return true
+				}
+				if sig, ok := pkg.TypesInfo.TypeOf(lit).(*types.Signature); ok && IsBracketFunc(sig) {
+					// only its signature, so as not to contain the findings in its body
+					define(closures[lit], "closure", sig, lit.Type.Pos(), lit.Type.End())
+				}
+				return true
+			})
+		}
+	}
+	disambiguate(found)
+	return found
+}
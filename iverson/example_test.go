@@ -0,0 +1,59 @@
+package iverson_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// A tool that has already type checked its files need not load them again.
+func ExampleAnalyze() {
+	const src = `package p
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func f(a, b int) (n int) {
+	if a > b {
+		n = 1
+	} else {
+		n = 0
+	}
+	return n + btoi(a == b)
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		panic(err)
+	}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	conf := types.Config{Importer: importer.Default()}
+	pkg, err := conf.Check("example.com/p", fset, []*ast.File{file}, info)
+	if err != nil {
+		panic(err)
+	}
+	found := iverson.Analyze(fset, []*ast.File{file}, info, pkg)
+	for _, f := range found {
+		fmt.Printf("%s: %s, proposed as %s\n", f.Pos, f.Message(), f.Proposed)
+	}
+	implicit, explicit := iverson.Count(found)
+	fmt.Println(implicit, "implicit,", explicit, "explicit")
+	// Output:
+	// p.go:11:2: implicit bool to number conversion (if), proposed as n = int(a > b)
+	// p.go:16:13: explicit bool to number conversion (call), proposed as int(a == b)
+	// 1 implicit, 1 explicit
+}
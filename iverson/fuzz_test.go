@@ -0,0 +1,119 @@
+package iverson
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FuzzFind runs the detectors over arbitrary source, which must never panic,
+// and checks that the counts for well-typed source do not change
+// under semantics preserving rewrites.
+func FuzzFind(f *testing.F) {
+	seeds, err := filepath.Glob("testdata/src/*/*.go")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		src, err := os.ReadFile(seed)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		pkg, ok := check(src)
+		if pkg == nil {
+			return
+		}
+		implicit, explicit := Count(Find(pkg, Options{}))
+		if !ok {
+			// only checking for panics in ill-typed code
+			return
+		}
+
+		for name, rewrite := range map[string]func(*ast.File){
+			"parenthesize": parenthesizeConds,
+			"swap":         swapBranches,
+		} {
+			file := pkg.Syntax[0]
+			rewrite(file)
+			var buf bytes.Buffer
+			if err := printer.Fprint(&buf, pkg.Fset, file); err != nil {
+				t.Fatal(err)
+			}
+			pkg2, ok := check(buf.Bytes())
+			if !ok {
+				t.Fatalf("%s: rewritten source does not type check:\n%s", name, buf.Bytes())
+			}
+			implicit2, explicit2 := Count(Find(pkg2, Options{}))
+			if implicit != implicit2 || explicit != explicit2 {
+				t.Errorf("%s: counts changed from %d implicit, %d explicit to %d implicit, %d explicit:\n%s", name, implicit, explicit, implicit2, explicit2, buf.Bytes())
+			}
+			pkg = pkg2
+		}
+	})
+}
+
+// check parses and type checks a single file package.
+// It returns a nil package if src does not parse
+// and false if it does not type check.
+func check(src []byte) (*packages.Package, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "fuzz.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	ok := true
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(error) { ok = false },
+	}
+	tpkg, _ := conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+	return &packages.Package{
+		ID:        file.Name.Name,
+		Fset:      fset,
+		Syntax:    []*ast.File{file},
+		Types:     tpkg,
+		TypesInfo: info,
+	}, ok
+}
+
+// parenthesizeConds wraps every if condition in parentheses.
+func parenthesizeConds(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n, ok := n.(*ast.IfStmt); ok {
+			n.Cond = &ast.ParenExpr{X: n.Cond}
+		}
+		return true
+	})
+}
+
+// swapBranches rewrites every if c { A } else { B } to if !(c) { B } else { A }.
+func swapBranches(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n, ok := n.(*ast.IfStmt); ok {
+			if els, ok := n.Else.(*ast.BlockStmt); ok {
+				n.Cond = &ast.UnaryExpr{Op: token.NOT, X: &ast.ParenExpr{X: n.Cond}}
+				n.Body, n.Else = els, n.Body
+			}
+		}
+		return true
+	})
+}
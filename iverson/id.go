@@ -0,0 +1,60 @@
+package iverson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// contentID hashes the parts into a short finding ID.
+func contentID(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// nodeText prints n with all runs of whitespace collapsed to a single space,
+// so that the result does not depend on how n was formatted.
+func nodeText(n ast.Node) string {
+	var b strings.Builder
+	// positions only affect line breaks, which are normalized away
+	if err := printer.Fprint(&b, token.NewFileSet(), n); err != nil {
+		return fmt.Sprintf("%T", n)
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// shape prints the structure of n: the type of every node, nested,
+// ignoring names, literal values, and operators,
+// so that findings generated from the same template have the same shape.
+func shape(n ast.Node) string {
+	var b strings.Builder
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			b.WriteString(")")
+			return true
+		}
+		fmt.Fprintf(&b, "(%T", n)
+		return true
+	})
+	return b.String()
+}
+
+// disambiguate appends an ordinal to the IDs of findings
+// after the first with the same content, in order.
+func disambiguate(found []Finding) {
+	seen := map[string]int{}
+	for i, f := range found {
+		seen[f.ID]++
+		if n := seen[f.ID]; n > 1 {
+			found[i].ID = fmt.Sprintf("%s-%d", f.ID, n)
+		}
+	}
+}
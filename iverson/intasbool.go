@@ -0,0 +1,156 @@
+package iverson
+
+import (
+	"cmp"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// FindIntAsBool reports the integer struct fields declared in pkg
+// that are only ever assigned the constants 0 and 1,
+// and 1 at least once: bools stored as integers.
+//
+// Only unexported fields are considered as any other package could assign to exported fields.
+// Fields whose address is taken, or that are updated in place, are never reported.
+func FindIntAsBool(pkg *packages.Package) []Finding {
+	info := pkg.TypesInfo
+	if info == nil {
+		return nil
+	}
+
+	// candidates maps each field to whether it has been set to 1
+	candidates := map[*types.Var]bool{}
+	for _, obj := range info.Defs {
+		if v, ok := obj.(*types.Var); ok && v.IsField() && !v.Exported() && integer(v.Type()) {
+			candidates[v] = false
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	field := func(x ast.Expr) *types.Var {
+		sel, ok := ast.Unparen(x).(*ast.SelectorExpr)
+		if !ok {
+			return nil
+		}
+		v, _ := info.Uses[sel.Sel].(*types.Var)
+		return v
+	}
+	// assign records that v is set to x, or to something unknown if x is nil.
+	assign := func(v *types.Var, x ast.Expr) {
+		if _, ok := candidates[v]; !ok {
+			return
+		}
+		var val constant.Value
+		if x != nil {
+			val = constant.ToInt(info.Types[x].Value)
+		}
+		switch {
+		case val == nil || val.Kind() != constant.Int:
+			delete(candidates, v)
+		case constant.Sign(val) == 0:
+		case constant.Compare(val, token.EQL, constant.MakeInt64(1)):
+			candidates[v] = true
+		default:
+			delete(candidates, v)
+		}
+	}
+
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				plain := n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs)
+				for i, lhs := range n.Lhs {
+					if plain {
+						assign(field(lhs), n.Rhs[i])
+					} else {
+						assign(field(lhs), nil)
+					}
+				}
+
+			case *ast.IncDecStmt:
+				assign(field(n.X), nil)
+
+			case *ast.RangeStmt:
+				if n.Key != nil {
+					assign(field(n.Key), nil)
+				}
+				if n.Value != nil {
+					assign(field(n.Value), nil)
+				}
+
+			case *ast.UnaryExpr:
+				if n.Op == token.AND {
+					assign(field(n.X), nil)
+				}
+
+			case *ast.CompositeLit:
+				typ := info.TypeOf(n)
+				if typ == nil {
+					return true
+				}
+				st, ok := typ.Underlying().(*types.Struct)
+				if !ok {
+					return true
+				}
+				for i, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						if id, ok := kv.Key.(*ast.Ident); ok {
+							v, _ := info.Uses[id].(*types.Var)
+							assign(v, kv.Value)
+						}
+					} else if i < st.NumFields() {
+						assign(st.Field(i), elt)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	var found []Finding
+	for v, one := range candidates {
+		if one {
+			var build, version string
+			for _, file := range pkg.Syntax {
+				if file.FileStart <= v.Pos() && v.Pos() < file.FileEnd {
+					build, version = buildConstraint(file), goVersion(pkg, file)
+				}
+			}
+			found = append(found, Finding{
+				ID:        contentID(pkg.PkgPath, "", IntAsBool, v.Name()+" "+v.Type().String()),
+				Content:   contentID("", IntAsBool, v.Name()+" "+v.Type().String()),
+				Shape:     contentID(IntAsBool, v.Type().String()),
+				Pos:       pkg.Fset.Position(v.Pos()),
+				End:       pkg.Fset.Position(v.Pos()),
+				Kind:      IntAsBool,
+				Form:      "field",
+				Type:      numericKind(v.Type()),
+				Build:     build,
+				GoVersion: version,
+			})
+		}
+	}
+	slices.SortFunc(found, func(a, b Finding) int {
+		return comparePos(a.Pos, b.Pos)
+	})
+	disambiguate(found)
+	return found
+}
+
+func comparePos(a, b token.Position) int {
+	return cmp.Or(strings.Compare(a.Filename, b.Filename), cmp.Compare(a.Offset, b.Offset))
+}
+
+func integer(typ types.Type) bool {
+	t, ok := typ.Underlying().(*types.Basic)
+	return ok && t.Info()&types.IsInteger != 0
+}
@@ -0,0 +1,1575 @@
+// Package iverson finds the bool to number conversions that golang/go#61915 proposes a builtin conversion for,
+// written out as if-else statements setting a number to 0 or 1 by a bool, calls of funcs from bool to number,
+// reads of maps from bool to number, and the like,
+// so that tools other than the issue61915 command can embed the analysis rather than run it.
+//
+// Packages loads packages the way the command does, Find reports the Findings in each, and Count tallies them.
+// Analyze and Analyzer work from packages that are already type checked,
+// and PotentialIversonIf, IsBracketFunc, IsMapBracket, and the other predicates
+// classify single statements and types for callers walking the syntax themselves.
+// RegisterTransform hooks post-processing of the findings into everything that reports them.
+package iverson
+
+import (
+	"cmp"
+	"context"
+	"go/ast"
+	"go/build/constraint"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/jimmyfrasche/issue61915/syntax"
+)
+
+// Finding kinds.
+const (
+	Implicit = "implicit"
+	Explicit = "explicit"
+	// Degenerate is a bracket call with a constant argument or whose result is compared against a constant.
+	// These are not counted as explicit.
+	Degenerate = "degenerate"
+	// IntAsBool is an integer struct field used as a bool, reported by FindIntAsBool.
+	IntAsBool = "int-as-bool"
+	// RoundTrip is a comparison against 0 or 1 turning a converted bool back into a bool.
+	RoundTrip = "round-trip"
+	// Unverified is an implicit if that cannot be type checked
+	// but whose branches syntactically set the same variable to the literals 0 and 1.
+	Unverified = "unverified"
+	// Increment is an if without an else that only increments or decrements a number,
+	// like if b { n++ } or if b { total += w },
+	// counting or weighing how many bools are true rather than selecting a value.
+	Increment = "increment"
+	// Probable is a func named like a bool to number helper that is not exactly a bracket func,
+	// reported by FindProbableHelpers with less confidence than the other kinds.
+	Probable = "probable-helper"
+	// Definition is the declaration of a bracket func or method, reported by FindDefinitions,
+	// counted apart from the explicit calls of it.
+	Definition = "definition"
+)
+
+// A Finding is a single potential bool to number conversion.
+type Finding struct {
+	// ID identifies the finding by its content rather than its position,
+	// so that it is stable across unrelated edits.
+	ID string
+	// Content is like ID but without the package,
+	// so that it is the same if the file of the finding moves to another package.
+	Content string
+	Pos     token.Position
+	End     token.Position // just after the finding, the same as Pos for a field, or after the name of a probable helper
+	Kind    string         // Implicit, Explicit, Degenerate, IntAsBool, RoundTrip, Unverified, Increment, Probable, or Definition
+	// Form is the syntax of the finding: "if", "init" for an if without an else after initializing the number,
+	// "switch", "call", "index" for a map read,
+	// "literal" for a read of a map literal, "ternary" for a call of a ternary helper,
+	// "unsafe" for a dereference of a bool pointer converted with unsafe.Pointer, "compare", "field",
+	// "func" for a probable helper or a definition, "closure" for a call or definition of a func literal,
+	// "return" for an if returning a number in each branch, or in its only branch and the statement after it, with Options.Returns,
+	// or "compound" for an increment by += or -= rather than ++ or --.
+	Form string
+	Func string // enclosing function or method, if any
+	Type string // basic numeric kind of the converted value, like int or uint8, if known
+	// Where is "defer" or "go" if the finding is in a closure run by a defer or go statement.
+	Where string
+	// API is "exported" if the finding is directly in an exported function or method of a package outside internal directories
+	// whose parameters are all bools, or slices, arrays, or pointers of them, and whose results are all numbers,
+	// like func Count(vals []bool) int, so that the conversion is what the package's API offers.
+	API string
+	// Usage is "index" if a bracket call or read is used in the index of an index expression,
+	// with Arity the number of them combined in that index, like arr[btoi(a)*2+btoi(b)].
+	// Otherwise it is "arithmetic" if it is combined with len or cap, used in the bounds of a slice expression,
+	// or added to a variable used as an index, all of which need the result to be an int,
+	// or "serialization" in methods like String or MarshalJSON,
+	// where bools are merely encoded for output.
+	Usage string
+	Arity int
+	// Alloc is "literal" for a read of a map literal or a conversion indexing a slice or map literal,
+	// which may allocate the literal every time, or "loop" if that is in a loop.
+	Alloc string
+	// Composed is the number of conversions composed in one expression if more than one,
+	// like 2 for b2i(a)*b2i(b) or b2i(b2i(a) > 0),
+	// or for an implicit if setting a variable converted earlier.
+	Composed int
+	// Values are the values chosen between by a ternary helper call or set or returned by an implicit finding,
+	// then and else, like "1,0", with "x" for any that is not constant.
+	Values string
+	// Rewrite classifies how an implicit if setting 0 or 1, or an increment, could be replaced by a conversion:
+	// "direct" if the then branch sets 1 or increments or decrements by 1, "invert" if it sets 0 so the condition must be negated,
+	// "scale" if it adds or subtracts another number that the conversion must be multiplied by,
+	// or "temporary" if an init statement must be kept as a separate statement.
+	// It is empty for other findings.
+	Rewrite string
+	// Proposed is the source of the finding as it could be written with the conversion golang/go#61915 proposes,
+	// on one line, like n = int(b), n += int(!ok), or return int(x > 0) * 4.
+	// It is empty if the finding could not be written as a conversion, as for degenerate findings
+	// or an implicit if choosing between two numbers neither of which is 0.
+	Proposed string
+	// Within is the ID of the finding this one overlaps and that takes precedence over it, if MarkOverlaps found one,
+	// like the implicit if around an explicit call in its condition.
+	Within string
+	// Shape hashes the structure of the finding, ignoring names and values,
+	// so that repeats like those in generated tables share it.
+	Shape string
+	// Cond is "reused" if the bool being converted is a local variable
+	// used again after the conversion, so the bool must stay,
+	// or "consumed" if the conversion is its only remaining use.
+	// It is empty for degenerate and other findings and for non-local variables.
+	Cond string
+
+	// Find leaves SSA, Reach, and Owner empty for its callers to set,
+	// as the issue61915 command does with -ssa, -deps, and -codeowners.
+
+	// SSA is "select" or "memory" for implicit findings whose variable was checked in SSA form.
+	SSA string
+	// Reach is "reachable" or "unreachable" from the main packages analyzed, if there are any.
+	Reach string
+	// Build is the //go:build constraint of the file, if any.
+	Build string
+	// GoVersion is the effective language version of the file, like go1.22,
+	// from the go directive of its module and any //go:build constraint, if known.
+	GoVersion string
+	// Owner lists the owners of the file by a CODEOWNERS file, if any.
+	Owner string
+	// Lines is the number of lines the source of the finding spans, from Pos to End,
+	// and Chars its length in bytes, so that the verbosity of each form can be measured.
+	// Both are 0 for a field.
+	Lines, Chars int
+
+	// For explicit and degenerate calls, the called function, named like F.func1 if a func literal,
+	// and not known for a local variable or parameter not bound to one,
+	// the import path of the package defining it,
+	// and the path of the module containing that package, if known.
+	Callee, CalleePkg, Module string
+}
+
+// span returns the number of lines from pos to end and the bytes between them.
+func span(pos, end token.Position) (lines, chars int) {
+	return end.Line - pos.Line + 1, end.Offset - pos.Offset
+}
+
+// Count returns the number of implicit and explicit findings.
+func Count(found []Finding) (implicit, explicit int) {
+	for _, f := range found {
+		switch f.Kind {
+		case Implicit:
+			implicit++
+		case Explicit:
+			explicit++
+		}
+	}
+	return implicit, explicit
+}
+
+// Options configure Find.
+type Options struct {
+	// Imported counts calls of package qualified bracket funcs, like pkg.Btoi(b),
+	// and of bracket methods declared in other packages.
+	Imported bool
+	// Returns counts ifs returning a number by a bool, like if b { return 1 }; return 0,
+	// which are mostly the bodies of the bracket funcs whose calls are counted.
+	Returns bool
+	// Prefilter skips inspecting the files without any of the tokens that start most findings,
+	// like x = 1, n++, or the name of a bracket func,
+	// missing any implicit ifs and switches in them choosing between other numbers, like x = lo.
+	// It reads the source of every file again, so it is only worth it when most files have none.
+	Prefilter bool
+	// Helpers are the funcs and methods whose calls always count as explicit conversions, even with other signatures,
+	// like func btoi(b bool) (int, error), by their full names as types.Func.FullName has them,
+	// like example.com/m.btoi or (*example.com/m.T).Btoi.
+	// They are counted by Imported or not.
+	Helpers map[string]bool
+	// NotHelpers are the funcs and methods, named as in Helpers, whose calls never count,
+	// though they are bracket funcs or ternary helpers, like a func(b bool) int that is not a conversion.
+	NotHelpers map[string]bool
+}
+
+type counter struct {
+	pkg         *packages.Package
+	opts        Options
+	fn          string // name of the function being inspected
+	where       string // Finding.Where of the function being inspected
+	api         string // Finding.API of the function being inspected
+	build       string // Finding.Build of the file being inspected
+	goVersion   string // Finding.GoVersion of the file being inspected
+	serializing bool   // whether the declaration being inspected is a serialization method
+	findings    []Finding
+
+	// nodes being inspected, innermost last
+	stack []ast.Node
+	// fn, where, and api to restore when leaving each function literal
+	saved []struct{ fn, where, api string }
+	// names of the func literals of the package
+	closures map[*ast.FuncLit]string
+	// variables bound to a bracket func literal, whose calls are calls of it
+	bound map[types.Object]*ast.FuncLit
+	// function literals run by defer and go statements
+	deferred map[*ast.FuncLit]string
+	// bracket expressions used as indices and the number in the same index
+	indices map[ast.Node]int
+	// bracket expressions and the number composed in the same expression
+	composed map[ast.Node]int
+	// bracket expressions indexing slice or map literals
+	tables map[ast.Node]bool
+
+	// calls whose result is compared against a constant
+	compared map[*ast.CallExpr]bool
+	// local variables set by a finding, checked for round trips
+	converted map[types.Object]bool
+
+	// names of bracket funcs and the like, for Options.Prefilter
+	helpers map[string]bool
+}
+
+func newCounter(pkg *packages.Package, opts Options) *counter {
+	return &counter{
+		pkg:       pkg,
+		opts:      opts,
+		compared:  map[*ast.CallExpr]bool{},
+		converted: map[types.Object]bool{},
+		closures:  closureNames(pkg.Syntax),
+		bound:     boundClosures(pkg.TypesInfo, pkg.Syntax),
+		deferred:  map[*ast.FuncLit]string{},
+		indices:   map[ast.Node]int{},
+		composed:  map[ast.Node]int{},
+		tables:    map[ast.Node]bool{},
+	}
+}
+
+func (c *counter) inspect(n ast.Node) bool {
+	if n == nil {
+		c.pop()
+		return true
+	}
+	kind := ""
+	var callee types.Object
+	var closure *ast.FuncLit // the func literal called by an explicit finding, if known
+	var typ types.Type
+	var rewrite string
+	var cond ast.Expr // the bool converted by an implicit or explicit finding
+	var form, values string
+	var proposed string // Finding.Proposed
+	composed := 0
+	switch n := n.(type) {
+	case *ast.IfStmt:
+		// if-else statement whose branches only set a number
+		if PotentialIversonIf(c.pkg, n) {
+			kind = Implicit
+			then, els := syntax.BranchAssign(n.Body), syntax.BranchAssign(n.Else.(*ast.BlockStmt))
+			typ, rewrite, composed = c.implicit(n.Init, then.Lhs[0], then.Rhs[0], els.Rhs[0])
+			values = c.values(then.Rhs[0], els.Rhs[0])
+			cond = n.Cond
+			proposed = c.selection(n.Init, typ, cond, n.Body, n.Else.(*ast.BlockStmt))
+		} else if c.unverifiedIf(n) {
+			kind = Unverified
+		} else if els, ok := c.initialized(n); ok {
+			kind, form = Implicit, "init"
+			then := syntax.BranchAssign(n.Body)
+			typ, rewrite, composed = c.implicit(n.Init, then.Lhs[0], then.Rhs[0], els)
+			values = c.values(then.Rhs[0], els)
+			cond = n.Cond
+			proposed = proposal(n.Init, assign(then.Lhs[0], token.ASSIGN, c.choice(typ, cond, then.Rhs[0], els)))
+		} else if then, els, ok := c.returns(n); ok {
+			kind, form = Implicit, "return"
+			typ, rewrite = c.pkg.TypesInfo.TypeOf(then), c.rewrite(n.Init, then, els)
+			values = c.values(then, els)
+			cond = n.Cond
+			if x := c.choice(typ, cond, then, els); x != nil {
+				proposed = proposal(n.Init, &ast.ReturnStmt{Results: []ast.Expr{x}})
+			}
+		} else if x, delta, ok := c.increment(n); ok {
+			kind = Increment
+			typ, rewrite = c.pkg.TypesInfo.TypeOf(x), "direct"
+			if delta != nil {
+				form = "compound"
+				if !c.isInt(delta, 1) {
+					rewrite = "scale"
+				}
+			}
+			if n.Init != nil {
+				rewrite = "temporary"
+			}
+			cond = n.Cond
+			tok := token.ADD_ASSIGN
+			switch s := n.Body.List[0].(type) {
+			case *ast.IncDecStmt:
+				if s.Tok == token.DEC {
+					tok = token.SUB_ASSIGN
+				}
+			case *ast.AssignStmt:
+				tok = s.Tok
+			}
+			by := times(c.conversion(typ, cond), delta, delta == nil || c.isInt(delta, 1))
+			proposed = proposal(n.Init, assign(x, tok, by))
+		} else {
+			// we need to manually scan the blocks and expressions to avoid false positives in else-if's
+			c.recurOnIf(n)
+			return false
+		}
+
+	case *ast.SwitchStmt:
+		// switch statement with two clauses that only set a number
+		if x, then, els, ok := SwitchBranches(c.pkg, n); ok {
+			kind = Implicit
+			a, b := syntax.BranchAssign(then), syntax.BranchAssign(els)
+			typ, rewrite, composed = c.implicit(n.Init, a.Lhs[0], a.Rhs[0], b.Rhs[0])
+			values = c.values(a.Rhs[0], b.Rhs[0])
+			cond = x
+			proposed = c.selection(n.Init, typ, cond, then, els)
+		}
+
+	case *ast.CallExpr:
+		// calling a func(~number) ~bool
+		if c.bracket(n) {
+			kind = Explicit
+			if c.compared[n] || len(n.Args) == 1 && c.constant(n.Args[0]) {
+				kind = Degenerate
+			} else if len(n.Args) == 1 {
+				cond = n.Args[0]
+			}
+			callee = typeutil.Callee(c.pkg.TypesInfo, n)
+			if closure = c.calledClosure(n); closure != nil {
+				form = "closure"
+			}
+			typ = c.pkg.TypesInfo.TypeOf(n)
+			proposed = proposal(nil, c.conversion(typ, cond))
+		} else if c.ternary(n) {
+			// calling a func(~bool, ~number, ~number) ~number choosing between them
+			kind, form = Explicit, "ternary"
+			if c.compared[n] || c.constant(n.Args[0]) {
+				kind = Degenerate
+			} else {
+				cond = n.Args[0]
+			}
+			values = c.values(n.Args[1], n.Args[2])
+			callee = typeutil.Callee(c.pkg.TypesInfo, n)
+			typ = c.pkg.TypesInfo.TypeOf(n)
+			proposed = proposal(nil, c.choice(typ, cond, n.Args[1], n.Args[2]))
+		}
+
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.EQL, token.NEQ, token.LSS, token.GTR, token.LEQ, token.GEQ:
+			for _, xy := range [][2]ast.Expr{{n.X, n.Y}, {n.Y, n.X}} {
+				x := ast.Unparen(xy[0])
+				// note calls compared against constants before visiting them
+				if call, ok := x.(*ast.CallExpr); ok && c.constant(xy[1]) {
+					c.compared[call] = true
+				}
+				// comparing a converted variable against 0 or 1 turns it back into a bool
+				if id, ok := x.(*ast.Ident); ok && c.converted[c.pkg.TypesInfo.ObjectOf(id)] && c.zeroOrOne(xy[1]) {
+					kind = RoundTrip
+					typ = c.pkg.TypesInfo.TypeOf(id)
+				}
+			}
+		}
+
+	case *ast.AssignStmt:
+		if len(n.Lhs) == len(n.Rhs) {
+			for i, x := range n.Rhs {
+				if c.bracket(x) {
+					c.convert(n.Lhs[i])
+				}
+			}
+		}
+
+	case *ast.ValueSpec:
+		if len(n.Names) == len(n.Values) {
+			for i, x := range n.Values {
+				if c.bracket(x) {
+					c.convert(n.Names[i])
+				}
+			}
+		}
+
+	case *ast.IndexExpr:
+		// reading from a map[~bool]~number
+		if c.bracket(n) {
+			kind = Explicit
+			typ = c.pkg.TypesInfo.TypeOf(n)
+			cond = n.Index
+			// only the values of a literal are known
+			if then, els, ok := c.literalValues(n.X); ok {
+				proposed = proposal(nil, c.choice(typ, cond, then, els))
+			}
+		}
+		c.noteIndex(n)
+
+	case *ast.StarExpr:
+		// reinterpreting a bool as a number, like *(*uint8)(unsafe.Pointer(&b))
+		if x, ok := c.unsafeConversion(n); ok {
+			kind = Explicit
+			typ = c.pkg.TypesInfo.TypeOf(n)
+			cond = x
+			proposed = proposal(nil, c.conversion(typ, cond))
+		}
+
+	case *ast.DeferStmt:
+		if lit, ok := ast.Unparen(n.Call.Fun).(*ast.FuncLit); ok {
+			c.deferred[lit] = "defer"
+		}
+
+	case *ast.GoStmt:
+		if lit, ok := ast.Unparen(n.Call.Fun).(*ast.FuncLit); ok {
+			c.deferred[lit] = "go"
+		}
+
+	case *ast.FuncLit:
+		c.enterLit(n)
+	}
+	if kind != "" {
+		f := Finding{
+			ID:      contentID(c.pkg.PkgPath, c.fn, kind, nodeText(n)),
+			Content: contentID(c.fn, kind, nodeText(n)),
+			Shape:   contentID(kind, shape(n)),
+			Pos:     c.pkg.Fset.Position(n.Pos()),
+			End:     c.pkg.Fset.Position(n.End()),
+			Kind:    kind,
+			Form:    cmp.Or(form, formOf(n)),
+			Func:    c.fn,
+			Type:    numericKind(typ),
+
+			Where:     c.where,
+			API:       c.api,
+			Build:     c.build,
+			GoVersion: c.goVersion,
+
+			Values:   values,
+			Rewrite:  rewrite,
+			Proposed: proposed,
+		}
+		f.Lines, f.Chars = span(f.Pos, f.End)
+		if cond != nil {
+			f.Cond = c.reuse(n, cond)
+		}
+		if arity := c.indices[n]; arity > 0 {
+			f.Usage, f.Arity = "index", arity
+		} else if kind != Implicit && kind != Increment && c.arithmetic(n) {
+			f.Usage = "arithmetic"
+		} else if c.serializing {
+			f.Usage = "serialization"
+		}
+		if kind != Implicit && kind != Increment {
+			composed = c.composition(n)
+		}
+		if x, ok := n.(*ast.IndexExpr); ok && c.allocates(x.X) || c.tables[n] {
+			f.Alloc = "literal"
+			if c.inLoop() {
+				f.Alloc = "loop"
+			}
+		}
+		if composed > 1 {
+			f.Composed = composed
+		}
+		if v, ok := callee.(*types.Var); ok && !v.IsField() && v.Pkg() != nil && v.Parent() != v.Pkg().Scope() {
+			// a local variable or parameter is not itself a helper
+			callee = nil
+		}
+		if closure != nil {
+			f.Callee, f.CalleePkg = c.pkg.PkgPath+"."+c.closures[closure], c.pkg.PkgPath
+			if c.pkg.Module != nil {
+				f.Module = c.pkg.Module.Path
+			}
+		} else if callee != nil && callee.Pkg() != nil {
+			f.Callee = callee.Pkg().Path() + "." + callee.Name()
+			if fn, ok := callee.(*types.Func); ok {
+				f.Callee = fn.FullName() // with the receiver type of a method
+			}
+			f.CalleePkg = callee.Pkg().Path()
+			if callee.Pkg() == c.pkg.Types && c.pkg.Module != nil {
+				f.Module = c.pkg.Module.Path
+			}
+		}
+		c.findings = append(c.findings, f)
+	}
+	c.stack = append(c.stack, n)
+	return true
+}
+
+// formOf returns the Finding.Form of a finding at n.
+func formOf(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.IfStmt:
+		return "if"
+	case *ast.SwitchStmt:
+		return "switch"
+	case *ast.CallExpr:
+		return "call"
+	case *ast.IndexExpr:
+		if _, ok := ast.Unparen(n.X).(*ast.CompositeLit); ok {
+			return "literal"
+		}
+		return "index"
+	case *ast.StarExpr:
+		return "unsafe"
+	case *ast.BinaryExpr:
+		return "compare"
+	}
+	return ""
+}
+
+// implicit returns the type, Finding.Rewrite, and Finding.Composed of the implicit if or switch
+// with the init statement init that sets lhs to then if its bool is true and els if it is false,
+// and records when it converts the bool to a variable.
+// If els is nil, lhs keeps its zero value.
+func (c *counter) implicit(init ast.Stmt, lhs, then, els ast.Expr) (typ types.Type, rewrite string, composed int) {
+	typ = c.pkg.TypesInfo.TypeOf(lhs)
+	if c.constant(then) && (els == nil || c.constant(els)) {
+		c.convert(lhs)
+	}
+	rewrite = c.rewrite(init, then, els)
+	for _, x := range []ast.Expr{then, els} {
+		if id, ok := x.(*ast.Ident); ok && c.converted[c.pkg.TypesInfo.ObjectOf(id)] {
+			composed = 2
+		}
+	}
+	return typ, rewrite, composed
+}
+
+// rewrite classifies an implicit if or switch with the init statement init
+// and branch values then and els, or the zero value if els is nil, for Finding.Rewrite.
+func (c *counter) rewrite(init ast.Stmt, then, els ast.Expr) string {
+	var rewrite string
+	switch {
+	case c.isInt(then, 1) && (els == nil || c.isInt(els, 0)):
+		rewrite = "direct"
+	case c.isInt(then, 0) && els != nil && c.isInt(els, 1):
+		rewrite = "invert"
+	default:
+		return ""
+	}
+	if init != nil {
+		return "temporary"
+	}
+	return rewrite
+}
+
+// reuse classifies the bool cond converted at site for Finding.Cond.
+// A use before site counts as after it if both are in a loop that the variable is declared outside of.
+func (c *counter) reuse(site ast.Node, cond ast.Expr) string {
+	x := ast.Unparen(cond)
+	for {
+		u, ok := x.(*ast.UnaryExpr)
+		if !ok || u.Op != token.NOT {
+			break
+		}
+		x = ast.Unparen(u.X)
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return "consumed"
+	}
+	v, ok := c.pkg.TypesInfo.ObjectOf(id).(*types.Var)
+	if !ok || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+		return ""
+	}
+
+	var body ast.Node
+	var loop ast.Node // outermost loop enclosing site but not the declaration of v
+	for i := len(c.stack) - 1; i >= 0 && body == nil; i-- {
+		switch n := c.stack[i].(type) {
+		case *ast.FuncLit:
+			body = n.Body
+		case *ast.FuncDecl:
+			body = n.Body
+		case *ast.ForStmt, *ast.RangeStmt:
+			if v.Pos() < n.Pos() || v.Pos() >= n.End() {
+				loop = n
+			}
+		}
+	}
+	if body == nil {
+		return ""
+	}
+
+	reused := false
+	assigned := map[*ast.Ident]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN {
+				for _, lhs := range n.Lhs {
+					if id, ok := ast.Unparen(lhs).(*ast.Ident); ok {
+						assigned[id] = true
+					}
+				}
+			}
+		case *ast.Ident:
+			if assigned[n] || c.pkg.TypesInfo.Uses[n] != v {
+				break
+			}
+			switch {
+			case n.Pos() >= site.Pos() && n.Pos() < site.End():
+			case n.Pos() >= site.End(), loop != nil && n.Pos() >= loop.Pos() && n.Pos() < loop.End():
+				reused = true
+			}
+		}
+		return !reused
+	})
+	if reused {
+		return "reused"
+	}
+	return "consumed"
+}
+
+// isInt reports whether x is a constant equal to v.
+func (c *counter) isInt(x ast.Expr, v int64) bool {
+	val := constant.ToInt(c.pkg.TypesInfo.Types[x].Value)
+	if val == nil || val.Kind() != constant.Int {
+		return false
+	}
+	n, exact := constant.Int64Val(val)
+	return exact && n == v
+}
+
+// composition returns the number of bracket expressions composed in the outermost expression containing the bracket expression x,
+// through arithmetic, comparisons, and the arguments of other bracket calls.
+func (c *counter) composition(x ast.Node) int {
+	if n, ok := c.composed[x]; ok {
+		return n
+	}
+	root := x
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		switch p := c.stack[i].(type) {
+		case *ast.BinaryExpr, *ast.UnaryExpr, *ast.ParenExpr:
+			root = p
+			continue
+		case *ast.CallExpr:
+			if c.bracket(p) {
+				root = p
+				continue
+			}
+		}
+		break
+	}
+	var brackets []ast.Node
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case ast.Expr:
+			if c.bracket(n) {
+				brackets = append(brackets, ast.Unparen(n))
+			}
+		}
+		return true
+	})
+	for _, b := range brackets {
+		c.composed[b] = len(brackets)
+	}
+	return c.composed[x]
+}
+
+// arithmetic reports whether the conversion x being inspected is in indexing arithmetic for Finding.Usage:
+// an arithmetic expression with len or cap, the bounds of a slice expression,
+// or the value added to or subtracted from a variable used as an index or slice bound in the same function.
+func (c *counter) arithmetic(x ast.Node) bool {
+	root, i := x, len(c.stack)-1
+	for ; i >= 0; i-- {
+		switch p := c.stack[i].(type) {
+		case *ast.BinaryExpr:
+			switch p.Op {
+			case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+				root = p
+				continue
+			}
+		case *ast.UnaryExpr, *ast.ParenExpr:
+			root = p
+			continue
+		}
+		break
+	}
+	lenOrCap := false
+	ast.Inspect(root, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if ok {
+			if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+				b, ok := c.pkg.TypesInfo.Uses[id].(*types.Builtin)
+				lenOrCap = lenOrCap || ok && (b.Name() == "len" || b.Name() == "cap")
+			}
+		}
+		_, lit := n.(*ast.FuncLit)
+		return !lit && !lenOrCap
+	})
+	if lenOrCap || i < 0 {
+		return lenOrCap
+	}
+	switch p := c.stack[i].(type) {
+	case *ast.SliceExpr:
+		return root == p.Low || root == p.High || root == p.Max
+	case *ast.AssignStmt:
+		if (p.Tok == token.ADD_ASSIGN || p.Tok == token.SUB_ASSIGN) && len(p.Lhs) == 1 && p.Rhs[0] == root {
+			return c.usedAsIndex(p.Lhs[0])
+		}
+	}
+	return false
+}
+
+// usedAsIndex reports whether x is a local variable used in an index or slice bound in the function being inspected.
+func (c *counter) usedAsIndex(x ast.Expr) bool {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	v, ok := c.pkg.TypesInfo.ObjectOf(id).(*types.Var)
+	if !ok {
+		return false
+	}
+	var body ast.Node
+	for i := len(c.stack) - 1; i >= 0 && body == nil; i-- {
+		switch n := c.stack[i].(type) {
+		case *ast.FuncLit:
+			body = n.Body
+		case *ast.FuncDecl:
+			body = n.Body
+		}
+	}
+	if body == nil {
+		return false
+	}
+	uses := func(x ast.Expr) bool {
+		found := false
+		ast.Inspect(x, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && c.pkg.TypesInfo.Uses[id] == v {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+	used := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			used = used || uses(n.Index)
+		case *ast.SliceExpr:
+			for _, bound := range []ast.Expr{n.Low, n.High, n.Max} {
+				used = used || bound != nil && uses(bound)
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+// noteIndex records the bracket expressions used in index,
+// except those in the indices of nested index expressions.
+// If the indexed expression is a slice or map literal, they are noted as indexing a table for Finding.Alloc.
+func (c *counter) noteIndex(n *ast.IndexExpr) {
+	index := n.Index
+	var brackets []ast.Node
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if x, ok := n.(ast.Expr); ok && c.bracket(x) {
+			brackets = append(brackets, ast.Unparen(x))
+		}
+		if x, ok := n.(*ast.IndexExpr); ok {
+			ast.Inspect(x.X, visit)
+			return false
+		}
+		return true
+	}
+	ast.Inspect(index, visit)
+	table := c.allocates(n.X)
+	for _, b := range brackets {
+		c.indices[b] = len(brackets)
+		if table {
+			c.tables[b] = true
+		}
+	}
+}
+
+// allocates reports whether x is a slice or map literal, which allocates each time it is evaluated
+// unless the compiler can keep it on the stack.
+func (c *counter) allocates(x ast.Expr) bool {
+	lit, ok := ast.Unparen(x).(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	switch c.pkg.TypesInfo.TypeOf(lit).(type) {
+	case *types.Slice, *types.Map:
+		return true
+	}
+	return false
+}
+
+// inLoop reports whether the node being inspected is in the body of a loop in the current function.
+func (c *counter) inLoop() bool {
+	for i := len(c.stack) - 1; i >= 0; i-- {
+		switch c.stack[i].(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		}
+	}
+	return false
+}
+
+// enterLit enters the closure lit, named as by closureNames,
+// and notes if it is run by a defer or go statement.
+func (c *counter) enterLit(lit *ast.FuncLit) {
+	c.saved = append(c.saved, struct{ fn, where, api string }{c.fn, c.where, c.api})
+	c.api = "" // a closure is not part of the API
+	c.fn = c.closures[lit]
+	if where := c.deferred[lit]; where != "" {
+		c.where = where
+	}
+}
+
+// pop leaves the innermost node being inspected.
+func (c *counter) pop() {
+	n := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	if _, ok := n.(*ast.FuncLit); ok {
+		saved := c.saved[len(c.saved)-1]
+		c.saved = c.saved[:len(c.saved)-1]
+		c.fn, c.where, c.api = saved.fn, saved.where, saved.api
+	}
+}
+
+func (c *counter) constant(x ast.Expr) bool {
+	return c.pkg.TypesInfo.Types[x].Value != nil
+}
+
+func (c *counter) zeroOrOne(x ast.Expr) bool {
+	return c.isInt(x, 0) || c.isInt(x, 1)
+}
+
+// bracket reports whether x is a call to a bracket func or a read from a bracket map,
+// or to one of Options.Helpers and not one of Options.NotHelpers.
+func (c *counter) bracket(x ast.Expr) bool {
+	switch x := ast.Unparen(x).(type) {
+	case *ast.CallExpr:
+		if listed, ok := c.listed(x); ok {
+			return listed
+		}
+		if sel, ok := x.Fun.(*ast.SelectorExpr); ok {
+			if c.qualified(sel) && !c.opts.Imported {
+				return false
+			}
+			// a method value has the type of the method without its receiver
+			if m, ok := c.pkg.TypesInfo.Selections[sel]; ok {
+				switch m.Kind() {
+				case types.MethodExpr:
+					return false
+				case types.MethodVal:
+					if m.Obj().Pkg() != c.pkg.Types && !c.opts.Imported {
+						return false
+					}
+				}
+			}
+		}
+		return IsBracketFunc(c.pkg.TypesInfo.TypeOf(x.Fun))
+	case *ast.IndexExpr:
+		return IsMapBracket(c.pkg.TypesInfo.TypeOf(x.X))
+	}
+	return false
+}
+
+// listed reports whether x calls one of Options.Helpers, or is false if it calls one of Options.NotHelpers,
+// and whether it calls either.
+func (c *counter) listed(x *ast.CallExpr) (helper, ok bool) {
+	fn, isFunc := typeutil.Callee(c.pkg.TypesInfo, x).(*types.Func)
+	if !isFunc {
+		return false, false
+	}
+	name := fn.Origin().FullName()
+	switch {
+	case c.opts.NotHelpers[name]:
+		return false, true
+	case c.opts.Helpers[name]:
+		return true, true
+	}
+	return false, false
+}
+
+// calledClosure returns the func literal x calls, either directly or by a variable bound to it.
+func (c *counter) calledClosure(x *ast.CallExpr) *ast.FuncLit {
+	switch fun := ast.Unparen(x.Fun).(type) {
+	case *ast.FuncLit:
+		return fun
+	case *ast.Ident:
+		return c.bound[c.pkg.TypesInfo.Uses[fun]]
+	}
+	return nil
+}
+
+// ternary reports whether x is a call of a ternary helper, like If(b, 1, 0).
+// Unlike bracket funcs, those from other packages are always counted,
+// as their definitions are not themselves findings.
+func (c *counter) ternary(x *ast.CallExpr) bool {
+	fun := x.Fun
+	switch ix := fun.(type) {
+	case *ast.IndexExpr:
+		fun = ix.X
+	case *ast.IndexListExpr:
+		fun = ix.X
+	}
+	if sel, ok := fun.(*ast.SelectorExpr); ok && !c.qualified(sel) {
+		return false
+	}
+	if listed, ok := c.listed(x); ok && !listed {
+		return false
+	}
+	return len(x.Args) == 3 && IsTernaryFunc(c.pkg.TypesInfo.TypeOf(x.Fun))
+}
+
+// unsafeConversion reports whether n dereferences a pointer to a ~bool converted to a pointer to a ~number with unsafe.Pointer.
+// It returns the bool too, if the pointer is its address.
+func (c *counter) unsafeConversion(n *ast.StarExpr) (ast.Expr, bool) {
+	info := c.pkg.TypesInfo
+	conv, ok := ast.Unparen(n.X).(*ast.CallExpr)
+	if !ok || len(conv.Args) != 1 || !info.Types[conv.Fun].IsType() {
+		return nil, false
+	}
+	if ptr, ok := info.TypeOf(conv.Fun).Underlying().(*types.Pointer); !ok || !numeric(ptr.Elem()) {
+		return nil, false
+	}
+	inner, ok := ast.Unparen(conv.Args[0]).(*ast.CallExpr)
+	if !ok || len(inner.Args) != 1 || !info.Types[inner.Fun].IsType() {
+		return nil, false
+	}
+	if t, ok := info.TypeOf(inner.Fun).(*types.Basic); !ok || t.Kind() != types.UnsafePointer {
+		return nil, false
+	}
+	arg := ast.Unparen(inner.Args[0])
+	if !typed(info.TypeOf(arg)) {
+		return nil, false
+	}
+	if ptr, ok := info.TypeOf(arg).Underlying().(*types.Pointer); !ok || !boolish(ptr.Elem()) {
+		return nil, false
+	}
+	if addr, ok := arg.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		return addr.X, true
+	}
+	return nil, true
+}
+
+// value returns the constant value of x for Finding.Values, or "x" if it is not constant.
+func (c *counter) value(x ast.Expr) string {
+	if v := c.pkg.TypesInfo.Types[x].Value; v != nil {
+		return v.ExactString()
+	}
+	return "x"
+}
+
+// values returns the values then and els, or the zero value if els is nil, for Finding.Values.
+func (c *counter) values(then, els ast.Expr) string {
+	if els == nil {
+		return c.value(then) + ",0"
+	}
+	return c.value(then) + "," + c.value(els)
+}
+
+// qualified reports whether sel is a package qualified identifier, like pkg.Name.
+func (c *counter) qualified(sel *ast.SelectorExpr) bool {
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = c.pkg.TypesInfo.Uses[id].(*types.PkgName)
+	return ok
+}
+
+// convert records that x, if it is a local variable, holds a converted bool.
+func (c *counter) convert(x ast.Expr) {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return
+	}
+	v, ok := c.pkg.TypesInfo.ObjectOf(id).(*types.Var)
+	if ok && v.Pkg() != nil && v.Parent() != nil && v.Parent() != v.Pkg().Scope() {
+		c.converted[v] = true
+	}
+}
+
+// returns reports whether n is an if returning a number by a bool with Options.Returns,
+// like if b { return 1 } else { return 0 } or if b { return 1 }; return 0,
+// and returns the number returned if the bool is true and if it is false.
+func (c *counter) returns(n *ast.IfStmt) (then, els ast.Expr, ok bool) {
+	if !c.opts.Returns {
+		return nil, nil, false
+	}
+	// number returns the number returned by the only statement of list
+	number := func(list []ast.Stmt) ast.Expr {
+		if len(list) != 1 {
+			return nil
+		}
+		ret, ok := list[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			return nil
+		}
+		switch x := ret.Results[0].(type) {
+		case *ast.BasicLit, *ast.Ident:
+			if numeric(c.pkg.TypesInfo.TypeOf(x)) {
+				return x
+			}
+		}
+		return nil
+	}
+	if then = number(n.Body.List); then == nil {
+		return nil, nil, false
+	}
+	switch e := n.Else.(type) {
+	case *ast.BlockStmt:
+		els = number(e.List)
+	case nil:
+		if len(c.stack) == 0 {
+			break
+		}
+		var list []ast.Stmt
+		switch p := c.stack[len(c.stack)-1].(type) {
+		case *ast.BlockStmt:
+			list = p.List
+		case *ast.CaseClause:
+			list = p.Body
+		case *ast.CommClause:
+			list = p.Body
+		}
+		if i := slices.Index(list, ast.Stmt(n)); i >= 0 && i+1 < len(list) {
+			els = number(list[i+1 : i+2])
+		}
+	}
+	return then, els, els != nil
+}
+
+// increment reports whether n is an if without an else whose body only increments or decrements a number x,
+// like if b { x++ } or if b { x -= delta }, and returns x and delta, which is nil for ++ and --.
+func (c *counter) increment(n *ast.IfStmt) (x, delta ast.Expr, ok bool) {
+	if n.Else != nil || len(n.Body.List) != 1 {
+		return nil, nil, false
+	}
+	switch s := n.Body.List[0].(type) {
+	case *ast.IncDecStmt:
+		x = s.X
+	case *ast.AssignStmt:
+		if (s.Tok != token.ADD_ASSIGN && s.Tok != token.SUB_ASSIGN) || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			return nil, nil, false
+		}
+		x, delta = s.Lhs[0], s.Rhs[0]
+	default:
+		return nil, nil, false
+	}
+	return x, delta, numeric(c.pkg.TypesInfo.TypeOf(x))
+}
+
+// initialized reports whether n is an if without an else that only sets a local variable to 1 or 0
+// immediately after the variable is set to the other, like x := 0; if b { x = 1 },
+// and returns that constant, or nil if it is the zero value of a var declaration.
+func (c *counter) initialized(n *ast.IfStmt) (ast.Expr, bool) {
+	// returning from the only branch leaves the statements after the if to the other
+	if n.Else != nil || !BranchOnlySetsNumber(c.pkg, n.Body) || syntax.BranchReturns(n.Body) || len(c.stack) == 0 {
+		return nil, false
+	}
+	assign := syntax.BranchAssign(n.Body)
+	id, ok := ast.Unparen(assign.Lhs[0]).(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	v, ok := c.pkg.TypesInfo.ObjectOf(id).(*types.Var)
+	if !ok || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+		return nil, false
+	}
+	then := assign.Rhs[0]
+	var list []ast.Stmt
+	switch p := c.stack[len(c.stack)-1].(type) {
+	case *ast.BlockStmt:
+		list = p.List
+	case *ast.CaseClause:
+		list = p.Body
+	case *ast.CommClause:
+		list = p.Body
+	}
+	i := slices.Index(list, ast.Stmt(n))
+	if i < 1 {
+		return nil, false
+	}
+	// is reports whether x is v
+	is := func(x ast.Expr) bool {
+		id, ok := ast.Unparen(x).(*ast.Ident)
+		return ok && c.pkg.TypesInfo.ObjectOf(id) == v
+	}
+	var init ast.Expr
+	switch prev := list[i-1].(type) {
+	case *ast.AssignStmt:
+		if (prev.Tok != token.ASSIGN && prev.Tok != token.DEFINE) || len(prev.Lhs) != 1 || len(prev.Rhs) != 1 || !is(prev.Lhs[0]) {
+			return nil, false
+		}
+		init = prev.Rhs[0]
+	case *ast.DeclStmt:
+		gen, ok := prev.Decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR || len(gen.Specs) != 1 {
+			return nil, false
+		}
+		spec := gen.Specs[0].(*ast.ValueSpec)
+		if len(spec.Names) != 1 || !is(spec.Names[0]) || len(spec.Values) > 1 {
+			return nil, false
+		}
+		if len(spec.Values) == 0 {
+			return nil, c.isInt(then, 1)
+		}
+		init = spec.Values[0]
+	default:
+		return nil, false
+	}
+	if !numeric(c.pkg.TypesInfo.TypeOf(init)) || !(c.isInt(init, 0) && c.isInt(then, 1) || c.isInt(init, 1) && c.isInt(then, 0)) {
+		return nil, false
+	}
+	return init, true
+}
+
+// unverifiedIf reports whether n is an if-else setting a variable of unknown type to 0 in one branch and 1 in the other.
+func (c *counter) unverifiedIf(n *ast.IfStmt) bool {
+	lhs, then, els, ok := syntax.IversonIf(n)
+	if !ok || typed(c.pkg.TypesInfo.TypeOf(lhs)) {
+		return false
+	}
+	a, ok := syntax.ZeroOrOne(then)
+	b, ok2 := syntax.ZeroOrOne(els)
+	return ok && ok2 && a != b
+}
+
+func (c *counter) recurOnIf(n *ast.IfStmt) {
+	if n.Init != nil {
+		ast.Inspect(n.Init, c.inspect)
+	}
+	ast.Inspect(n.Cond, c.inspect)
+	ast.Inspect(n.Body, c.inspect)
+	switch Else := n.Else.(type) {
+	case nil:
+	case *ast.IfStmt:
+		c.recurOnIf(Else)
+	case *ast.BlockStmt:
+		ast.Inspect(Else, c.inspect)
+	}
+}
+
+// Find reports the findings in pkg.
+// Where type information is missing, only Unverified implicit ifs are found.
+func Find(pkg *packages.Package, opts Options) []Finding {
+	found, _ := FindContext(context.Background(), pkg, opts)
+	return found
+}
+
+// FindContext is Find, but stops between files once ctx is done,
+// returning the findings in the files before and the error of ctx.
+func FindContext(ctx context.Context, pkg *packages.Package, opts Options) ([]Finding, error) {
+	if pkg.TypesInfo == nil {
+		p := *pkg
+		p.TypesInfo = &types.Info{}
+		pkg = &p
+	}
+	c := newCounter(pkg, opts)
+	for _, file := range pkg.Syntax {
+		if err := ctx.Err(); err != nil {
+			disambiguate(c.findings)
+			return c.findings, err
+		}
+		if opts.Prefilter && !c.candidates(file) {
+			slog.Debug("skipping file", "file", pkg.Fset.Position(file.Pos()).Filename, "reason", "prefilter")
+			continue
+		}
+		c.build, c.goVersion = buildConstraint(file), goVersion(pkg, file)
+		for _, decl := range file.Decls {
+			c.fn, c.api, c.serializing = "", "", false
+			if decl, ok := decl.(*ast.FuncDecl); ok {
+				c.fn, c.api = funcName(decl), c.apiSurface(decl)
+				c.serializing = decl.Recv != nil && serializationMethods[decl.Name.Name]
+			}
+			ast.Inspect(decl, c.inspect)
+		}
+	}
+	disambiguate(c.findings)
+	return c.findings, nil
+}
+
+// Analyze is Find for callers that have already type checked the files of pkg,
+// such as other analysis tools, and so need not load it again.
+// Where info is nil or incomplete, findings are Unverified as with Find.
+func Analyze(fset *token.FileSet, files []*ast.File, info *types.Info, pkg *types.Package) []Finding {
+	return analyze(fset, files, info, pkg, Options{})
+}
+
+func analyze(fset *token.FileSet, files []*ast.File, info *types.Info, pkg *types.Package, opts Options) []Finding {
+	p := &packages.Package{
+		Fset:      fset,
+		Syntax:    files,
+		Types:     pkg,
+		TypesInfo: info,
+	}
+	if pkg != nil {
+		p.ID, p.Name, p.PkgPath = pkg.Path(), pkg.Name(), pkg.Path()
+	}
+	return Find(p, opts)
+}
+
+// goVersion returns the effective language version of file in pkg, or "" if it is unknown.
+func goVersion(pkg *packages.Package, file *ast.File) string {
+	if v := pkg.TypesInfo.FileVersions[file]; v != "" {
+		return v
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		return "go" + pkg.Module.GoVersion
+	}
+	return ""
+}
+
+// serializationMethods are the names of methods that encode their receiver for output.
+var serializationMethods = map[string]bool{
+	"String":        true,
+	"GoString":      true,
+	"Format":        true,
+	"MarshalJSON":   true,
+	"MarshalText":   true,
+	"MarshalBinary": true,
+	"MarshalXML":    true,
+	"MarshalYAML":   true,
+	"AppendText":    true,
+	"AppendBinary":  true,
+}
+
+// buildConstraint returns the normalized //go:build expression of file, or "".
+func buildConstraint(file *ast.File) string {
+	for _, cg := range file.Comments {
+		if cg.Pos() >= file.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			if expr, err := constraint.Parse(c.Text); err == nil {
+				return expr.String()
+			}
+		}
+	}
+	return ""
+}
+
+// apiSurface returns the Finding.API of the findings directly in decl.
+func (c *counter) apiSurface(decl *ast.FuncDecl) string {
+	if !decl.Name.IsExported() || c.pkg.Name == "main" || slices.Contains(strings.Split(c.pkg.PkgPath, "/"), "internal") {
+		return ""
+	}
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		recv := decl.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if x, ok := recv.(*ast.IndexExpr); ok {
+			recv = x.X
+		} else if x, ok := recv.(*ast.IndexListExpr); ok {
+			recv = x.X
+		}
+		if id, ok := recv.(*ast.Ident); !ok || !id.IsExported() {
+			return ""
+		}
+	}
+	fn, ok := c.pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return ""
+	}
+	sig := fn.Signature()
+	if sig.Params().Len() == 0 || sig.Results().Len() == 0 {
+		return ""
+	}
+	for v := range sig.Params().Variables() {
+		if !bools(v.Type()) {
+			return ""
+		}
+	}
+	for v := range sig.Results().Variables() {
+		if !numeric(v.Type()) {
+			return ""
+		}
+	}
+	return "exported"
+}
+
+// bools reports whether typ is a bool or a slice, array, or pointer of bools.
+func bools(typ types.Type) bool {
+	for {
+		switch t := typ.Underlying().(type) {
+		case *types.Slice:
+			typ = t.Elem()
+		case *types.Array:
+			typ = t.Elem()
+		case *types.Pointer:
+			typ = t.Elem()
+		case *types.Basic:
+			return t.Info()&types.IsBoolean != 0
+		default:
+			return false
+		}
+	}
+}
+
+// funcName returns the name of decl, qualified by its receiver type for methods.
+func funcName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	return "(" + types.ExprString(decl.Recv.List[0].Type) + ")." + decl.Name.Name
+}
+
+// PotentialIversonIf reports whether cond is an if-else whose branches only set the same variable to a number,
+// either both returning after or neither.
+func PotentialIversonIf(pkg *packages.Package, cond *ast.IfStmt) bool {
+	if cond.Else == nil {
+		return false
+	}
+	elseBlock, ok := cond.Else.(*ast.BlockStmt)
+	if !ok {
+		return false
+	}
+	return BranchOnlySetsNumber(pkg, cond.Body) && BranchOnlySetsNumber(pkg, elseBlock) && syntax.SameBranches(cond.Body, elseBlock)
+}
+
+// PotentialIversonSwitch is PotentialIversonIf for a switch with two clauses that only set a number,
+// choosing between them by a bool:
+// either a switch without a tag whose clauses are one bool expression and default,
+// or a switch on a bool whose clauses are true and false, or one of them and default.
+func PotentialIversonSwitch(pkg *packages.Package, n *ast.SwitchStmt) bool {
+	_, _, _, ok := SwitchBranches(pkg, n)
+	return ok
+}
+
+// SwitchBranches returns the bool chosen on by the switch n, if it is a PotentialIversonSwitch,
+// and the bodies of the clauses for true and false.
+func SwitchBranches(pkg *packages.Package, n *ast.SwitchStmt) (cond ast.Expr, then, els *ast.BlockStmt, ok bool) {
+	if len(n.Body.List) != 2 {
+		return nil, nil, nil, false
+	}
+	var clauses [2]*ast.CaseClause
+	for i, stmt := range n.Body.List {
+		clauses[i] = stmt.(*ast.CaseClause)
+		if len(clauses[i].List) > 1 {
+			return nil, nil, nil, false
+		}
+	}
+	// value returns true, false, or default for the clause cc
+	value := func(cc *ast.CaseClause) string {
+		switch {
+		case cc.List == nil:
+			return "default"
+		case n.Tag == nil:
+			return "true"
+		}
+		if v := pkg.TypesInfo.Types[cc.List[0]].Value; v != nil && v.Kind() == constant.Bool {
+			return strconv.FormatBool(constant.BoolVal(v))
+		}
+		return ""
+	}
+	if n.Tag == nil {
+		if clauses[0].List != nil && clauses[1].List != nil {
+			return nil, nil, nil, false
+		}
+	} else if !boolish(pkg.TypesInfo.TypeOf(n.Tag)) {
+		return nil, nil, nil, false
+	}
+	bodies := map[string]*ast.BlockStmt{}
+	for _, cc := range clauses {
+		bodies[value(cc)] = &ast.BlockStmt{List: cc.Body}
+	}
+	then, els = bodies["true"], bodies["false"]
+	switch {
+	case then == nil:
+		then = bodies["default"]
+	case els == nil:
+		els = bodies["default"]
+	}
+	if then == nil || els == nil || !BranchOnlySetsNumber(pkg, then) || !BranchOnlySetsNumber(pkg, els) || !syntax.SameBranches(then, els) {
+		return nil, nil, nil, false
+	}
+	cond = n.Tag
+	if cond == nil {
+		for _, cc := range clauses {
+			if cc.List != nil {
+				cond = cc.List[0]
+			}
+		}
+	}
+	return cond, then, els, true
+}
+
+// BranchOnlySetsNumber true for an if without an else whose body is just x = n for a ~number which is either a literal or ident
+func BranchOnlySetsNumber(pkg *packages.Package, body *ast.BlockStmt) bool {
+	assign := syntax.BranchAssign(body)
+	if assign == nil {
+		return false
+	}
+	if assign.Tok != token.ASSIGN {
+		return false
+	}
+	if len(assign.Lhs) != 1 || !typed(pkg.TypesInfo.TypeOf(assign.Lhs[0])) {
+		return false
+	}
+	x := assign.Rhs[0]
+	switch x.(type) {
+	case *ast.BasicLit, *ast.Ident:
+	default:
+		return false
+	}
+	return numeric(pkg.TypesInfo.TypeOf(x))
+}
+
+// IsTernaryFunc returns true if typ is a func from a ~bool and two of the same ~number to that ~number,
+// like an instance of func If[T any](cond bool, then, els T) T.
+func IsTernaryFunc(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	sig, ok := typ.Underlying().(*types.Signature)
+	if !ok || sig.Recv() != nil || sig.Variadic() {
+		return false
+	}
+	in, out := sig.Params(), sig.Results()
+	if in.Len() != 3 || out.Len() != 1 {
+		return false
+	}
+	t := out.At(0).Type()
+	return boolish(in.At(0).Type()) && numeric(t) && types.Identical(in.At(1).Type(), t) && types.Identical(in.At(2).Type(), t)
+}
+
+// IsBracketFunc returns true if the typ is a func from a ~bool to a ~number.
+// Either may be a type parameter constrained to them,
+// as in the signature of func Btoi[T constraints.Integer](b bool) T or of its calls in other generic code.
+func IsBracketFunc(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	sig, ok := typ.Underlying().(*types.Signature)
+	if !ok {
+		return false
+	}
+	if sig.Recv() != nil {
+		return false
+	}
+	in, out := sig.Params(), sig.Results()
+	if in == nil || out == nil {
+		return false
+	}
+	if in.Len() != 1 || out.Len() != 1 || sig.Variadic() {
+		return false
+	}
+	in0, out0 := in.At(0).Type(), out.At(0).Type()
+	return (boolish(in0) || constrained(in0, boolish)) && (numeric(out0) || constrained(out0, numeric))
+}
+
+// constrained reports whether typ is a type parameter whose constraint only permits types satisfying ok,
+// like T ~bool for boolish or T constraints.Integer for numeric.
+func constrained(typ types.Type, ok func(types.Type) bool) bool {
+	tp, isParam := types.Unalias(typ).(*types.TypeParam)
+	if !isParam {
+		return false
+	}
+	// only reports whether every type in the type set of t satisfies ok
+	var only func(t types.Type) bool
+	only = func(t types.Type) bool {
+		switch t := t.Underlying().(type) {
+		case *types.Union:
+			for term := range t.Terms() {
+				if !only(term.Type()) {
+					return false
+				}
+			}
+			return t.Len() > 0
+		case *types.Interface:
+			// the type set is the intersection of those of the embedded elements
+			for e := range t.EmbeddedTypes() {
+				if only(e) {
+					return true
+				}
+			}
+			return false
+		case *types.TypeParam:
+			return false
+		}
+		return ok(t)
+	}
+	return only(tp.Constraint())
+}
+
+func IsMapBracket(typ types.Type) bool {
+	m, ok := typ.(*types.Map)
+	if !ok {
+		return false
+	}
+	return boolish(m.Key()) && numeric(m.Elem())
+}
+
+func boolish(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	t, ok := types.Default(typ).Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	return t.Kind() == types.Bool
+}
+
+// numericKind returns the name of the basic numeric type underlying typ, or "".
+func numericKind(typ types.Type) string {
+	if !numeric(typ) {
+		return ""
+	}
+	// byte and rune are reported as uint8 and int32
+	return types.Typ[types.Default(typ).Underlying().(*types.Basic).Kind()].Name()
+}
+
+// typed reports whether typ is known and valid.
+func typed(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	t, ok := typ.(*types.Basic)
+	return !ok || t.Kind() != types.Invalid
+}
+
+func numeric(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	t, ok := types.Default(typ).Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	switch t.Kind() {
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64, types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Float32, types.Float64, types.Complex64, types.Complex128:
+		return true
+	}
+	return false
+
+}
+
+// DropGenerated returns found without the findings in generated files,
+// those with a // Code generated ... DO NOT EDIT. comment before the package clause,
+// and the counts of those dropped by kind.
+func DropGenerated(files []*ast.File, fset *token.FileSet, found []Finding) ([]Finding, map[string]int) {
+	gen := map[string]bool{}
+	for _, file := range files {
+		if ast.IsGenerated(file) {
+			gen[fset.Position(file.Pos()).Filename] = true
+		}
+	}
+	if len(gen) == 0 {
+		return found, nil
+	}
+	dropped := map[string]int{}
+	found = slices.DeleteFunc(found, func(f Finding) bool {
+		if gen[f.Pos.Filename] {
+			dropped[f.Kind]++
+			return true
+		}
+		return false
+	})
+	return found, dropped
+}
@@ -0,0 +1,279 @@
+package iverson
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// TestFind checks the findings in each package under testdata/src against
+// the // want "kind" ... comments on the line of each expected finding.
+func TestFind(t *testing.T) {
+	for _, pkg := range loadTestdata(t, "./...") {
+		t.Run(pkg.ID, func(t *testing.T) {
+			want := wants(t, pkg)
+			got := map[string][]string{}
+			for _, f := range append(Find(pkg, Options{}), FindIntAsBool(pkg)...) {
+				key := fmt.Sprintf("%s:%d", f.Pos.Filename, f.Pos.Line)
+				got[key] = append(got[key], f.Kind)
+			}
+			for key, kinds := range got {
+				if !slices.Equal(kinds, want[key]) {
+					t.Errorf("%s: got %q, want %q", key, kinds, want[key])
+				}
+			}
+			for key, kinds := range want {
+				if _, ok := got[key]; !ok {
+					t.Errorf("%s: got nothing, want %q", key, kinds)
+				}
+			}
+		})
+	}
+}
+
+func loadTestdata(t *testing.T, pattern ...string) []*packages.Package {
+	t.Helper()
+	ps, err := Packages(context.Background(), "testdata/src", pattern, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ps
+}
+
+// wants collects the expectations of the // want comments in pkg keyed by file:line.
+func wants(t *testing.T, pkg *packages.Package) map[string][]string {
+	t.Helper()
+	want := map[string][]string{}
+	for _, file := range pkg.Syntax {
+		for _, cg := range file.Comments {
+			for _, c := range cg.List {
+				rest, ok := strings.CutPrefix(c.Text, "// want ")
+				if !ok {
+					continue
+				}
+				pos := pkg.Fset.Position(c.Pos())
+				key := fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+				want[key] = append(want[key], unquoteAll(t, pos.String(), rest)...)
+			}
+		}
+	}
+	return want
+}
+
+func unquoteAll(t *testing.T, pos, s string) []string {
+	t.Helper()
+	var out []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			t.Fatalf("%s: malformed want comment: %v", pos, err)
+		}
+		v, _ := strconv.Unquote(q)
+		out = append(out, v)
+		s = s[len(q):]
+	}
+	return out
+}
+
+func TestIDStable(t *testing.T) {
+	ids := func(src string) []string {
+		t.Helper()
+		pkg, ok := check([]byte(src))
+		if !ok {
+			t.Fatalf("does not type check:\n%s", src)
+		}
+		var ids []string
+		for _, f := range Find(pkg, Options{}) {
+			ids = append(ids, f.ID)
+		}
+		return ids
+	}
+
+	before := ids(`package p
+
+func f(a, b bool) (x, y int) {
+	if a { x = 1 } else { x = 0 }
+	if b { x = 1 } else { x = 0 }
+	return x, y
+}`)
+	after := ids(`package p
+
+var unrelated = 1
+
+func f(a, b bool) (x, y int) {
+	if a {
+		x = 1
+	} else {
+		x = 0
+	}
+	if b { x = 1 } else { x = 0 }
+	return x, y
+}`)
+	if len(before) != 2 || before[0] == before[1] {
+		t.Fatalf("want 2 distinct ids, got %q", before)
+	}
+	if !slices.Equal(before, after) {
+		t.Errorf("ids changed from %q to %q", before, after)
+	}
+
+	moved := ids(`package p
+
+func g(a, b bool) (x, y int) {
+	if a { x = 1 } else { x = 0 }
+	if b { x = 1 } else { x = 0 }
+	return x, y
+}`)
+	if slices.Equal(before, moved) {
+		t.Errorf("ids in different functions are the same: %q", moved)
+	}
+}
+
+func TestFindUnverified(t *testing.T) {
+	pkg, ok := check([]byte(`package p
+
+func f(a bool) (x int) {
+	var y undefined
+	if a { y = 1 } else { y = 0 }
+	if a { y = 0 } else { y = 1 }
+	if a { y = 1 } else { y = 2 }
+	if a { y = 1 } else { x = 0 }
+	if a { x = 1 } else { x = 0 }
+	return x
+}`))
+	if ok {
+		t.Fatal("want type errors")
+	}
+	var kinds []string
+	for _, f := range Find(pkg, Options{}) {
+		kinds = append(kinds, fmt.Sprintf("%d:%s", f.Pos.Line, f.Kind))
+	}
+	want := []string{"5:unverified", "6:unverified", "9:implicit"}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("got %q, want %q", kinds, want)
+	}
+
+	// without any type information at all
+	pkg.TypesInfo = nil
+	kinds = kinds[:0]
+	for _, f := range Find(pkg, Options{}) {
+		kinds = append(kinds, fmt.Sprintf("%d:%s", f.Pos.Line, f.Kind))
+	}
+	want = []string{"5:unverified", "6:unverified", "9:unverified"}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("without types: got %q, want %q", kinds, want)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	pkg, ok := check([]byte(`package p
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func f(a bool) (x int) {
+	if a { x = 1 } else { x = 0 }
+	return x + btoi(a)
+}`))
+	if !ok {
+		t.Fatal("does not type check")
+	}
+	pkg.PkgPath = pkg.Types.Path()
+	want := Find(pkg, Options{})
+	got := Analyze(pkg.Fset, pkg.Syntax, pkg.TypesInfo, pkg.Types)
+	if len(want) != 2 || !slices.Equal(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+// TestPrefilter checks that Options.Prefilter only skips files without findings.
+func TestPrefilter(t *testing.T) {
+	for _, pkg := range loadTestdata(t, "./...") {
+		want := Find(pkg, Options{})
+		got := Find(pkg, Options{Prefilter: true})
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: with prefilter got %+v\nwant %+v", pkg.ID, got, want)
+		}
+	}
+}
+
+// TestFindContext checks that FindContext stops before any file once its context is done.
+func TestFindContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, pkg := range loadTestdata(t, "./...") {
+		found, err := FindContext(ctx, pkg, Options{})
+		if err != context.Canceled || len(found) > 0 {
+			t.Errorf("%s: got %d findings and %v, want none and %v", pkg.ID, len(found), err, context.Canceled)
+		}
+		found, err = FindContext(context.Background(), pkg, Options{})
+		if want := Find(pkg, Options{}); err != nil || !slices.Equal(found, want) {
+			t.Errorf("%s: got %+v and %v\nwant %+v", pkg.ID, found, err, want)
+		}
+	}
+}
+
+func TestIsBracketFuncGeneric(t *testing.T) {
+	pkg, ok := check([]byte(`package p
+
+type integer interface{ ~int | ~uint8 }
+
+type boolean interface{ ~bool }
+
+func btoi[T integer](b bool) T { return 0 }
+
+func conv[B boolean, T interface{ integer | ~float64 }](b B) T { return 0 }
+
+func anyOut[T any](b bool) T { var t T; return t }
+
+func mixed[T ~int | ~string](b bool) T { var t T; return t }
+
+func notBool[B ~bool | ~int](b B) int { return 0 }
+`))
+	if !ok {
+		t.Fatal("does not type check")
+	}
+	want := map[string]bool{"btoi": true, "conv": true, "anyOut": false, "mixed": false, "notBool": false}
+	for name, bracket := range want {
+		obj := pkg.Types.Scope().Lookup(name)
+		if got := IsBracketFunc(obj.Type()); got != bracket {
+			t.Errorf("IsBracketFunc(%s) = %v, want %v", obj.Type(), got, bracket)
+		}
+	}
+}
+
+func TestApplyTransforms(t *testing.T) {
+	defer func(saved []Transform) { transforms = saved }(transforms)
+	transforms = nil
+
+	RegisterTransform(func(found []Finding) []Finding {
+		return slices.DeleteFunc(found, func(f Finding) bool { return f.Kind == Degenerate })
+	})
+	RegisterTransform(func(found []Finding) []Finding {
+		for i := range found {
+			found[i].Owner = "team-" + found[i].Func
+		}
+		return found
+	})
+
+	got := ApplyTransforms([]Finding{
+		{Kind: Implicit, Func: "f"},
+		{Kind: Degenerate, Func: "g"},
+		{Kind: Explicit, Func: "h"},
+	})
+	want := []Finding{
+		{Kind: Implicit, Func: "f", Owner: "team-f"},
+		{Kind: Explicit, Func: "h", Owner: "team-h"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
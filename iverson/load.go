@@ -0,0 +1,211 @@
+package iverson
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// LoadOptions are the options for loading packages.
+type LoadOptions struct {
+	// Deps loads all the dependencies of the packages too.
+	Deps bool
+	// Tests loads the test variants and external test packages too, as go test builds them;
+	// see DedupeTests.
+	Tests bool
+	// Tags are the comma separated build tags to satisfy, as with go build -tags.
+	Tags string
+	// Platform is the GOOS/GOARCH to load the packages for, if not that of the environment.
+	// It is appended to the ID of each package loaded, as SplitPlatform says,
+	// so that those of different platforms are told apart.
+	Platform string
+	// Overlay replaces the contents of the files at these absolute paths, as with packages.Config.Overlay,
+	// like the unsaved contents of files open in an editor.
+	Overlay map[string][]byte
+}
+
+// Packages loads the packages matching pattern, in dir if not empty, as opts says.
+func Packages(ctx context.Context, dir string, pattern []string, opts LoadOptions) ([]*packages.Package, error) {
+	cfg := &packages.Config{
+		Context: ctx,
+		Dir:     dir,
+		Tests:   opts.Tests,
+		Overlay: opts.Overlay,
+
+		Mode: packages.NeedName | packages.NeedTypesInfo | packages.NeedTypes | packages.NeedSyntax | packages.NeedFiles | packages.NeedModule,
+	}
+	if opts.Deps {
+		cfg.Mode |= packages.NeedImports | packages.NeedDeps
+	}
+	if opts.Tags != "" {
+		cfg.BuildFlags = []string{"-tags=" + opts.Tags}
+	}
+	if opts.Platform != "" {
+		goos, goarch, _ := strings.Cut(opts.Platform, "/")
+		cfg.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch)
+	}
+	start := time.Now()
+	ps, err := packages.Load(cfg, pattern...)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("loaded packages", "patterns", pattern, "platform", opts.Platform, "packages", len(ps), "elapsed", time.Since(start))
+	if errs := loadErrors(ps); len(errs) > 0 {
+		return nil, &LoadError{Errors: errs}
+	}
+	if len(ps) == 0 {
+		return nil, fmt.Errorf("no packages to load")
+	}
+	if opts.Platform != "" {
+		packages.Visit(ps, nil, func(p *packages.Package) {
+			p.ID += " [" + opts.Platform + "]"
+		})
+	}
+	return ps, nil
+}
+
+// LoadError is returned by Packages when any package, or any of its dependencies, failed to load.
+type LoadError struct {
+	Errors []PackageError
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("could not load packages: %d errors", len(e.Errors))
+}
+
+// PackageError is a single error reported by go/packages.
+type PackageError struct {
+	Package string `json:"package"`
+	Pos     string `json:"pos,omitempty"`
+	Msg     string `json:"msg"`
+	Kind    string `json:"kind"` // list, parse, or type
+}
+
+// loadErrors collects the errors of ps and their dependencies in the same order as packages.PrintErrors.
+func loadErrors(ps []*packages.Package) []PackageError {
+	var errs []PackageError
+	packages.Visit(ps, nil, func(pkg *packages.Package) {
+		for _, err := range pkg.Errors {
+			errs = append(errs, PackageError{
+				Package: pkg.ID,
+				Pos:     err.Pos,
+				Msg:     err.Msg,
+				Kind:    errorKind(err.Kind),
+			})
+		}
+	})
+	return errs
+}
+
+func errorKind(k packages.ErrorKind) string {
+	switch k {
+	case packages.ListError:
+		return "list"
+	case packages.ParseError:
+		return "parse"
+	case packages.TypeError:
+		return "type"
+	}
+	return "unknown"
+}
+
+// IsTest reports whether pkg is a test variant of a package,
+// an external test package, or a generated test main.
+func IsTest(pkg *packages.Package) bool {
+	id, _ := SplitPlatform(pkg.ID)
+	return strings.Contains(id, " [") || strings.HasSuffix(id, ".test")
+}
+
+// SplitPlatform splits the GOOS/GOARCH that Packages appends to the ID of each package it loads for a platform,
+// as in example.com/m [linux/amd64] or example.com/m [example.com/m.test] [linux/amd64],
+// from the ID go list gave it.
+func SplitPlatform(id string) (string, string) {
+	i := strings.LastIndex(id, " [")
+	if i < 0 || !strings.HasSuffix(id, "]") || strings.HasSuffix(id, ".test]") {
+		return id, ""
+	}
+	return id[:i], id[i+len(" [") : len(id)-len("]")]
+}
+
+// DedupeTests drops the generated test mains from ps, and the files of each test variant of a package,
+// like p [p.test], that are in the package itself or another variant before it,
+// so that each file is analyzed once, in the package without its tests where there is one.
+// A test variant then only has its _test.go files, and is left out if it has none.
+func DedupeTests(ps []*packages.Package) []*packages.Package {
+	seen := map[string]bool{}
+	file := func(pkg *packages.Package, f *ast.File) string {
+		return pkg.Fset.Position(f.FileStart).Filename
+	}
+	id := func(pkg *packages.Package) string {
+		id, _ := SplitPlatform(pkg.ID)
+		return id
+	}
+	for _, pkg := range ps {
+		if !IsTest(pkg) {
+			for _, f := range pkg.Syntax {
+				seen[file(pkg, f)] = true
+			}
+		}
+	}
+	var kept []*packages.Package
+	for _, pkg := range ps {
+		switch {
+		case !IsTest(pkg):
+			kept = append(kept, pkg)
+			continue
+		case strings.HasSuffix(id(pkg), ".test"):
+			slog.Debug("skipping package", "pkg", pkg.ID, "reason", "test main")
+			continue
+		}
+		var files []*ast.File
+		for _, f := range pkg.Syntax {
+			if name := file(pkg, f); !seen[name] {
+				seen[name] = true
+				files = append(files, f)
+			}
+		}
+		if len(files) == 0 {
+			slog.Debug("skipping package", "pkg", pkg.ID, "reason", "no test files")
+			continue
+		}
+		p := *pkg
+		p.Syntax = files
+		kept = append(kept, &p)
+	}
+	return kept
+}
+
+// DedupePlatforms drops the files of each package in ps that one before it, loaded for another platform, has,
+// so that a file is analyzed once, for the first platform that builds it,
+// and the packages for the others only have the files that are theirs alone.
+// Packages left without any files are dropped.
+func DedupePlatforms(ps []*packages.Package) []*packages.Package {
+	seen := map[string]bool{}
+	var kept []*packages.Package
+	for _, pkg := range ps {
+		var files []*ast.File
+		for _, f := range pkg.Syntax {
+			if name := pkg.Fset.Position(f.FileStart).Filename; !seen[name] {
+				seen[name] = true
+				files = append(files, f)
+			}
+		}
+		if len(files) == 0 {
+			slog.Debug("skipping package", "pkg", pkg.ID, "reason", "no files for the platform alone")
+			continue
+		}
+		if len(files) < len(pkg.Syntax) {
+			p := *pkg
+			p.Syntax = files
+			pkg = &p
+		}
+		kept = append(kept, pkg)
+	}
+	return kept
+}
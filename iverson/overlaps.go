@@ -0,0 +1,97 @@
+package iverson
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+)
+
+// overlapPrecedence ranks the kinds of finding for CollapseOverlaps, first first.
+// The ifs that select or count a number by a bool take precedence over any conversions in their conditions,
+// and conversions over the degenerate ones and round trips nested in them.
+var overlapPrecedence = []string{Implicit, Increment, Unverified, Explicit, Degenerate, RoundTrip, IntAsBool, Probable, Definition}
+
+// CollapseOverlaps returns found without the findings that overlap another of higher precedence,
+// where one overlaps another if its source is within the other's.
+// Of two overlapping findings of the same kind, the outer one is kept,
+// so that btoi(btoi(a) > 0) is counted once.
+// Overlapping findings are only ever nested within each other:
+// each is a single statement or expression.
+func CollapseOverlaps(found []Finding) []Finding {
+	into := overlapped(found)
+	var kept []Finding
+	for i, f := range found {
+		if into[i] < 0 {
+			kept = append(kept, f)
+		} else {
+			slog.Debug("collapsed finding", "pos", f.Pos, "kind", f.Kind, "into", found[into[i]].Pos, "into_kind", found[into[i]].Kind)
+		}
+	}
+	return kept
+}
+
+// MarkOverlaps sets the Within of each finding that CollapseOverlaps would drop
+// to the ID of the one it would be collapsed into, keeping them all.
+func MarkOverlaps(found []Finding) {
+	into := overlapped(found)
+	for i, j := range into {
+		if j >= 0 {
+			found[i].Within = found[j].ID
+		}
+	}
+}
+
+// overlapped returns the index of the finding each in found is collapsed into by CollapseOverlaps, or -1 if it is kept.
+func overlapped(found []Finding) []int {
+	order := make([]int, len(found))
+	for i := range order {
+		order[i] = i
+	}
+	// by file, then outer before inner
+	slices.SortStableFunc(order, func(a, b int) int {
+		fa, fb := found[a], found[b]
+		return cmp.Or(
+			cmp.Compare(fa.Pos.Filename, fb.Pos.Filename),
+			cmp.Compare(fa.Pos.Offset, fb.Pos.Offset),
+			cmp.Compare(fb.End.Offset, fa.End.Offset),
+		)
+	})
+	rank := func(f Finding) int { return slices.Index(overlapPrecedence, f.Kind) }
+	into := make([]int, len(found))
+	for i := range into {
+		into[i] = -1
+	}
+	var outer []int // findings enclosing the current one, innermost last
+	for _, i := range order {
+		f := found[i]
+		for len(outer) > 0 {
+			o := found[outer[len(outer)-1]]
+			if o.Pos.Filename == f.Pos.Filename && f.End.Offset <= o.End.Offset {
+				break
+			}
+			outer = outer[:len(outer)-1]
+		}
+		for _, j := range outer {
+			if into[j] < 0 && rank(found[j]) <= rank(f) {
+				into[i] = j
+				break
+			}
+		}
+		if into[i] < 0 {
+			// any enclosing findings left have lower precedence
+			for _, j := range outer {
+				if into[j] < 0 {
+					into[j] = i
+				}
+			}
+		}
+		outer = append(outer, i)
+	}
+	// a finding may be collapsed into one that is itself collapsed later
+	for i := range into {
+		for into[i] >= 0 && into[into[i]] >= 0 {
+			into[i] = into[into[i]]
+		}
+	}
+	return into
+}
@@ -0,0 +1,157 @@
+package iverson
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// candidates reports whether the source of file has any token that could start a finding, or a sequence of them,
+// so that files without one need not be inspected:
+// a 0 or 1 assigned with = or returned, like x = 1,
+// an increment or decrement outside the header of a for statement, like n++ or n += w,
+// a map type keyed by a bool, unsafe,
+// or the name of anything in the package whose type leads to a bracket func, a ternary helper, or a bracket map,
+// of a number constant valued 0 or 1, or of one of Options.Helpers.
+//
+// It can miss the findings with none of those:
+// implicit ifs and switches choosing between other numbers, like x = lo or x = 5,
+// and bracket funcs and maps reached without naming anything that leads to them,
+// as when converting to the type of one, like (func(bool) int)(f)(b).
+// Files that cannot be read, or whose package has no type information, are always candidates.
+func (c *counter) candidates(file *ast.File) bool {
+	tf := c.pkg.Fset.File(file.Pos())
+	if tf == nil || c.pkg.TypesInfo == nil {
+		return true
+	}
+	src, err := os.ReadFile(tf.Name())
+	if err != nil {
+		return true
+	}
+	if c.helpers == nil {
+		c.helpers = helperNames(c.pkg.TypesInfo)
+		for name := range c.opts.Helpers {
+			c.helpers[name[strings.LastIndex(name, ".")+1:]] = true
+		}
+	}
+	var s scanner.Scanner
+	s.Init(token.NewFileSet().AddFile(tf.Name(), -1, len(src)), src, nil, 0)
+	var last [4]token.Token // the tokens before this one, most recent first
+	inFor := false          // in the header of a for statement
+	for {
+		_, tok, lit := s.Scan()
+		switch tok {
+		case token.EOF:
+			return false
+		case token.FOR:
+			inFor = true
+		case token.LBRACE:
+			inFor = false
+		case token.INC, token.DEC, token.ADD_ASSIGN, token.SUB_ASSIGN:
+			if !inFor {
+				return true
+			}
+		case token.INT:
+			switch last[0] {
+			case token.ASSIGN, token.RETURN:
+				if zeroOrOne(lit) {
+					return true
+				}
+			}
+		case token.IDENT:
+			if lit == "unsafe" || c.helpers[lit] {
+				return true
+			}
+			// the key of map[bool]int, map[Flag]int, or map[pkg.Flag]int
+			if last[0] == token.LBRACK && last[1] == token.MAP && (lit == "bool" || c.helpers[mapKey+lit]) ||
+				last == [4]token.Token{token.PERIOD, token.IDENT, token.LBRACK, token.MAP} && c.helpers[mapKey+lit] {
+				return true
+			}
+		}
+		copy(last[1:], last[:3])
+		last[0] = tok
+	}
+}
+
+// mapKey prefixes the names of bool types in the names noted by helperNames,
+// to be told from the names of values.
+const mapKey = "map key "
+
+// zeroOrOne reports whether the integer literal lit is 0 or 1, in any base.
+func zeroOrOne(lit string) bool {
+	v, err := strconv.ParseInt(strings.ReplaceAll(lit, "_", ""), 0, 64)
+	return err == nil && (v == 0 || v == 1)
+}
+
+// helperNames returns the names of the funcs, methods, variables, and fields used or defined in info
+// whose types lead to bracket funcs, ternary helpers, or bracket maps, as by leadsToBracket,
+// the names of number constants valued 0 or 1,
+// and the names of bool types, with the mapKey prefix.
+func helperNames(info *types.Info) map[string]bool {
+	names := map[string]bool{}
+	note := func(id *ast.Ident, obj types.Object, typ types.Type) {
+		if typ == nil {
+			return
+		}
+		switch obj := obj.(type) {
+		case *types.Const:
+			if v := constant.ToInt(obj.Val()); numeric(typ) && v.Kind() == constant.Int && (constant.Sign(v) == 0 || constant.Compare(v, token.EQL, constant.MakeInt64(1))) {
+				names[id.Name] = true
+			}
+			return
+		case *types.TypeName:
+			if boolish(typ) {
+				names[mapKey+id.Name] = true
+			}
+			return
+		}
+		if leadsToBracket(typ, 0) {
+			names[id.Name] = true
+		}
+	}
+	for _, m := range []map[*ast.Ident]types.Object{info.Defs, info.Uses} {
+		for id, obj := range m {
+			if obj != nil {
+				note(id, obj, obj.Type())
+			}
+		}
+	}
+	for id, inst := range info.Instances {
+		note(id, nil, inst.Type)
+	}
+	return names
+}
+
+// leadsToBracket reports whether typ is that of a bracket func, a ternary helper, or a bracket map,
+// or of a func returning one, or a slice, array, map, or pointer of one, and so on a few levels deep,
+// like func() func(bool) int for the mk of mk()(b).
+func leadsToBracket(typ types.Type, depth int) bool {
+	if typ == nil || depth > 3 {
+		return false
+	}
+	if sig, ok := typ.(*types.Signature); ok && sig.Recv() != nil {
+		// the method as called, without its receiver
+		typ = types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+	}
+	if IsBracketFunc(typ) || IsTernaryFunc(typ) || IsMapBracket(typ.Underlying()) {
+		return true
+	}
+	switch t := typ.Underlying().(type) {
+	case *types.Signature:
+		return t.Results().Len() == 1 && leadsToBracket(t.Results().At(0).Type(), depth+1)
+	case *types.Slice:
+		return leadsToBracket(t.Elem(), depth+1)
+	case *types.Array:
+		return leadsToBracket(t.Elem(), depth+1)
+	case *types.Map:
+		return leadsToBracket(t.Elem(), depth+1)
+	case *types.Pointer:
+		return leadsToBracket(t.Elem(), depth+1)
+	}
+	return false
+}
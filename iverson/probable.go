@@ -0,0 +1,91 @@
+package iverson
+
+import (
+	"go/ast"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// helperName matches the names of bool to number helpers once lowercased and without underscores.
+var helperName = regexp.MustCompile(`^(b|bool)(2|to)(i|u|int|uint|num|number|byte|f|float)(8|16|32|64)?$`)
+
+// IsHelperName reports whether name is like that of a bool to number helper, as FindProbableHelpers reports them,
+// like b2i, btoi, BoolToInt, Bool2Int, or bool_to_uint8.
+func IsHelperName(name string) bool {
+	return helperName.MatchString(strings.ToLower(strings.ReplaceAll(name, "_", "")))
+}
+
+// FindProbableHelpers reports the funcs and methods declared in pkg that are named like bool to number helpers
+// and take a bool, or a pointer to one, but are not exactly bracket funcs,
+// like func btoi(ctx context.Context, b bool) int or func BoolToInt(b bool) *int,
+// so that calls of them are not counted as explicit conversions.
+// These are only probable helpers: the name is all there is to go on.
+func FindProbableHelpers(pkg *packages.Package) []Finding {
+	if pkg.TypesInfo == nil {
+		return nil
+	}
+	var found []Finding
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.FuncDecl)
+			if !ok || !IsHelperName(decl.Name.Name) {
+				continue
+			}
+			fn, ok := pkg.TypesInfo.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			sig := fn.Signature()
+			noRecv := types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+			if IsBracketFunc(noRecv) || sig.Results().Len() == 0 || !takesBool(sig) {
+				continue
+			}
+			var typ types.Type
+			for v := range sig.Results().Variables() {
+				t := v.Type()
+				if p, ok := t.Underlying().(*types.Pointer); ok {
+					t = p.Elem()
+				}
+				if numeric(t) {
+					typ = t
+					break
+				}
+			}
+			name := funcName(decl)
+			found = append(found, Finding{
+				ID:        contentID(pkg.PkgPath, name, Probable, sig.String()),
+				Content:   contentID(name, Probable, sig.String()),
+				Shape:     contentID(Probable, sig.String()),
+				Pos:       pkg.Fset.Position(decl.Name.Pos()),
+				End:       pkg.Fset.Position(decl.Name.End()),
+				Kind:      Probable,
+				Form:      "func",
+				Func:      name,
+				Type:      numericKind(typ),
+				Build:     buildConstraint(file),
+				GoVersion: goVersion(pkg, file),
+			})
+			f := &found[len(found)-1]
+			f.Lines, f.Chars = span(f.Pos, f.End)
+		}
+	}
+	disambiguate(found)
+	return found
+}
+
+// takesBool reports whether any parameter of sig is a ~bool or a pointer to one.
+func takesBool(sig *types.Signature) bool {
+	for v := range sig.Params().Variables() {
+		t := v.Type()
+		if p, ok := t.Underlying().(*types.Pointer); ok {
+			t = p.Elem()
+		}
+		if boolish(t) {
+			return true
+		}
+	}
+	return false
+}
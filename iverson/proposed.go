@@ -0,0 +1,127 @@
+package iverson
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/jimmyfrasche/issue61915/syntax"
+)
+
+// conversion returns the conversion of the bool cond to typ that golang/go#61915 proposes, like int(b),
+// or nil if cond or typ is not known.
+func (c *counter) conversion(typ types.Type, cond ast.Expr) ast.Expr {
+	if b, ok := typ.(*types.Basic); cond == nil || !typed(typ) || ok && b.Info()&types.IsUntyped != 0 {
+		return nil
+	}
+	name := types.TypeString(typ, func(p *types.Package) string {
+		if p == c.pkg.Types {
+			return ""
+		}
+		return p.Name()
+	})
+	return &ast.CallExpr{Fun: ast.NewIdent(name), Args: []ast.Expr{ast.Unparen(cond)}}
+}
+
+// choice returns the conversion of cond to typ that selects then if it is true and els if it is false,
+// or the zero value if els is nil: int(b) for 1 and 0, int(!b) for 0 and 1,
+// and int(b) * x for x and 0,
+// or nil if neither is 0 or typ is not known.
+func (c *counter) choice(typ types.Type, cond, then, els ast.Expr) ast.Expr {
+	if els == nil || c.isInt(els, 0) {
+		return times(c.conversion(typ, cond), then, c.isInt(then, 1))
+	}
+	if c.isInt(then, 0) {
+		return times(c.conversion(typ, negate(cond)), els, c.isInt(els, 1))
+	}
+	return nil
+}
+
+// literalValues returns the values for true and false of x, if it is a map literal keyed by constant bools,
+// with els nil if there is none for false.
+func (c *counter) literalValues(x ast.Expr) (then, els ast.Expr, ok bool) {
+	lit, ok := ast.Unparen(x).(*ast.CompositeLit)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return nil, nil, false
+		}
+		v := c.pkg.TypesInfo.Types[kv.Key].Value
+		if v == nil || v.Kind() != constant.Bool {
+			return nil, nil, false
+		}
+		if constant.BoolVal(v) {
+			then = kv.Value
+		} else {
+			els = kv.Value
+		}
+	}
+	return then, els, then != nil
+}
+
+// times returns x * y, or x if one.
+func times(x, y ast.Expr, one bool) ast.Expr {
+	if x == nil || one {
+		return x
+	}
+	return &ast.BinaryExpr{X: x, Op: token.MUL, Y: parens(y)}
+}
+
+// negate returns !x, or the operand of x if it is already negated.
+func negate(x ast.Expr) ast.Expr {
+	x = ast.Unparen(x)
+	if x == nil {
+		return nil
+	}
+	if u, ok := x.(*ast.UnaryExpr); ok && u.Op == token.NOT {
+		return u.X
+	}
+	return &ast.UnaryExpr{Op: token.NOT, X: parens(x)}
+}
+
+// parens returns x in parentheses if it is a binary expression, so it can be an operand.
+func parens(x ast.Expr) ast.Expr {
+	if _, ok := ast.Unparen(x).(*ast.BinaryExpr); ok {
+		return &ast.ParenExpr{X: ast.Unparen(x)}
+	}
+	return x
+}
+
+// assign returns the statement lhs tok x, or nil if x is nil.
+func assign(lhs ast.Expr, tok token.Token, x ast.Expr) ast.Stmt {
+	if x == nil {
+		return nil
+	}
+	return &ast.AssignStmt{Lhs: []ast.Expr{lhs}, Tok: tok, Rhs: []ast.Expr{x}}
+}
+
+// proposal prints n, after the init statement of the if or switch it replaces, if any, for Finding.Proposed,
+// or returns "" if n is nil.
+func proposal(init ast.Stmt, n ast.Node) string {
+	if n == nil {
+		return ""
+	}
+	if init != nil {
+		return nodeText(init) + "; " + nodeText(n)
+	}
+	return nodeText(n)
+}
+
+// selection returns the Finding.Proposed of an if or switch choosing by cond between the branches then and els,
+// like n = int(b), followed by a return if both branches return after setting the number,
+// or "" if they do not set the same variable or only one returns.
+func (c *counter) selection(init ast.Stmt, typ types.Type, cond ast.Expr, then, els *ast.BlockStmt) string {
+	if !syntax.SameBranches(then, els) {
+		return ""
+	}
+	a, b := syntax.BranchAssign(then), syntax.BranchAssign(els)
+	p := proposal(init, assign(a.Lhs[0], token.ASSIGN, c.choice(typ, cond, a.Rhs[0], b.Rhs[0])))
+	if p != "" && syntax.BranchReturns(then) {
+		p += "; return"
+	}
+	return p
+}
@@ -0,0 +1,73 @@
+package call
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type myBool bool
+
+func mtoi(b myBool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func calls(a, b bool) int {
+	n := btoi(a)              // want "explicit"
+	n += int(mtoi(myBool(b))) // want "explicit"
+	f := btoi
+	n += f(a) // want "explicit"
+	return n
+}
+
+func count(bs ...bool) int {
+	n := 0
+	for _, b := range bs {
+		n += btoi(b) // want "explicit"
+	}
+	return n
+}
+
+type T struct{}
+
+func (T) Btoi(b bool) int { return btoi(b) } // want "explicit"
+
+type I interface{ Weight(bool) float64 }
+
+type S struct {
+	btoi func(bool) int
+}
+
+func methods(t T, p *T, i I, s S, b bool) float64 {
+	n := t.Btoi(b) + T{}.Btoi(b)    // want "explicit" "explicit"
+	n += p.Btoi(!b)                 // want "explicit"
+	n += s.btoi(b)                  // want "explicit"
+	return float64(n) + i.Weight(b) // want "explicit"
+}
+
+const debug = false
+
+func degenerate(a bool) bool {
+	n := btoi(true)                       // want "degenerate"
+	n += btoi(debug)                      // want "degenerate"
+	return btoi(a) == 1 || 0 < (btoi(!a)) // want "degenerate" "degenerate"
+}
+
+// the remaining calls must not be reported
+
+func btos(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func notCounted(a bool) {
+	_ = btos(a)
+	_ = count(a)
+	_ = T.Btoi(T{}, a)
+}
@@ -0,0 +1,34 @@
+package call
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+func gbtoi[T integer](b bool) T {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func gconv[B ~bool, T integer](b B) T {
+	return gbtoi[T](bool(b)) // want "explicit"
+}
+
+func gcount[T integer](bs []bool) (n T) {
+	for _, b := range bs {
+		n += gconv[bool, T](b) // want "explicit"
+	}
+	return n
+}
+
+func gweigh[T any](b bool, weight func(bool) T) T {
+	return weight(b)
+}
+
+func gcalls(a bool, c myBool) float64 {
+	n := gbtoi[int](a)                // want "explicit"
+	n += int(gconv[myBool, uint8](c)) // want "explicit"
+	// weight is not constrained to numbers
+	return float64(n + gcount[int](nil) + int(gweigh(a, gbtoi[int8])))
+}
@@ -0,0 +1,316 @@
+package ifelse
+
+func literals(b bool) int {
+	var x int
+	if b { // want "implicit"
+		x = 1
+	} else {
+		x = 0
+	}
+	return x
+}
+
+func idents(b bool, one, zero float64) float64 {
+	var x float64
+	if b { // want "implicit"
+		x = one
+	} else {
+		x = zero
+	}
+	return x
+}
+
+type myInt int
+
+func named(b bool) myInt {
+	var x myInt
+	if b { // want "implicit"
+		x = 1
+	} else {
+		x = 0
+	}
+	return x
+}
+
+func nested(a, b bool) (x, y int) {
+	if a {
+		x = 1
+		if b { // want "implicit"
+			y = 1
+		} else {
+			y = 0
+		}
+	}
+	return
+}
+
+func blocks(b bool) int {
+	var x int
+	if b { // want "implicit"
+		{
+			x = 1
+		}
+	} else {
+		x = 0
+	}
+	return x
+}
+
+func namedResult(b bool) (n int) {
+	if b { // want "implicit"
+		n = 1
+		return
+	} else {
+		n = 0
+		return
+	}
+}
+
+func noElse(b bool) int {
+	x := 0
+	if b { // want "implicit"
+		x = 1
+	}
+	return x
+}
+
+func noElseVar(a, b bool) (int, uint8) {
+	var x int
+	if a { // want "implicit"
+		x = 1
+	}
+	var y = uint8(1)
+	if b { // want "implicit"
+		y = 0
+	}
+	return x, y
+}
+
+func noElseAssign(bs []bool) (n int) {
+	for _, b := range bs {
+		n = 0
+		if !b { // want "implicit"
+			n = 1
+		}
+	}
+	return n
+}
+
+// the remaining functions must not be reported
+
+func noElseLater(b bool) int {
+	x := 0
+	println()
+	if b {
+		x = 1
+	}
+	return x
+}
+
+func noElseOtherNumbers(b bool) int {
+	r := 1
+	if b {
+		r = 4
+	}
+	return r
+}
+
+func noElseOtherConstant(b bool, limit int) int {
+	n := 0
+	if b {
+		n = limit
+	}
+	return n
+}
+
+type level int
+
+func noElseNegative(b bool) level {
+	lvl := level(-1)
+	if b {
+		lvl = 1
+	}
+	return lvl
+}
+
+func noElseSame(b bool) int {
+	k := 1
+	if b {
+		k = 1
+	}
+	return k
+}
+
+func noElseZeroValueSetToZero(b bool) int {
+	var x int
+	if b {
+		x = 0
+	}
+	return x
+}
+
+func noElseComputed(b bool, y int) int {
+	x := y
+	if b {
+		x = 1
+	}
+	return x
+}
+
+func noElseOther(b bool) (x, y int) {
+	y = 0
+	if b {
+		x = 1
+	}
+	return x, y
+}
+
+func returnValue(b bool) (n int) {
+	if b {
+		n = 1
+		return n
+	} else {
+		n = 0
+		return
+	}
+}
+
+func returnOneBranch(b bool) (n int) {
+	if b {
+		n = 1
+		return
+	} else {
+		n = 0
+	}
+	n += 5
+	return
+}
+
+func noElseReturn(b bool) (n int) {
+	n = 0
+	if b {
+		n = 1
+		return
+	}
+	n += 5
+	return
+}
+
+func otherVariable(b bool) (int, int) {
+	var x, y int
+	if b {
+		x = 1
+	} else {
+		y = 0
+	}
+	return x, y
+}
+
+func elseIf(a, b bool) int {
+	var x int
+	if a {
+		x = 1
+	} else if b {
+		x = 2
+	} else {
+		x = 3
+	}
+	return x
+}
+
+func define(b bool) {
+	if b {
+		x := 1
+		_ = x
+	} else {
+		x := 0
+		_ = x
+	}
+}
+
+func compound(b bool, x int) int {
+	if b {
+		x += 1
+	} else {
+		x -= 1
+	}
+	return x
+}
+
+func strs(b bool) string {
+	var s string
+	if b {
+		s = "yes"
+	} else {
+		s = "no"
+	}
+	return s
+}
+
+func computed(b bool, y int) int {
+	var x int
+	if b {
+		x = y + 1
+	} else {
+		x = 0
+	}
+	return x
+}
+
+func extra(b bool) int {
+	var x int
+	if b {
+		x = 1
+		println("here")
+	} else {
+		x = 0
+	}
+	return x
+}
+
+func countTrue(bs []bool, weights map[string]float64) (n, m int) {
+	for _, b := range bs {
+		if b { // want "increment"
+			n++
+		}
+		if !b { // want "increment"
+			m--
+		}
+	}
+	if _, ok := weights["x"]; ok { // want "increment"
+		weights["x"]++
+	}
+	return n, m
+}
+
+func compoundAssign(bs []bool, penalty float64) (total int, score float64) {
+	for _, b := range bs {
+		if b { // want "increment"
+			total += 1
+		}
+		if !b { // want "increment"
+			score -= penalty
+		}
+	}
+	var s string
+	if len(bs) > 0 {
+		s += "x"
+	}
+	if score < 0 {
+		total *= 2
+	}
+	return total + len(s), score
+}
+
+func notCounting(a, b bool, n int) int {
+	if a {
+		n++
+	} else {
+		n--
+	}
+	if b {
+		n++
+		println()
+	}
+	return n
+}
@@ -0,0 +1,44 @@
+package intasbool
+
+type state struct {
+	dirty   int   // want "int-as-bool"
+	visible uint8 // want "int-as-bool"
+	keyed   int   // want "int-as-bool"
+	unkeyed int   // want "int-as-bool"
+
+	// the remaining fields must not be reported
+	count    int
+	never    int
+	zero     int
+	two      int
+	addr     int
+	computed int
+	Exported int
+	ratio    float64
+}
+
+func (s *state) update(b bool, n int) {
+	if b { // want "implicit"
+		s.dirty = 1
+	} else {
+		s.dirty = 0
+	}
+	if b {
+		s.visible = 1
+	}
+	s.count++
+	s.zero = 0
+	s.two = 2
+	p := &s.addr
+	*p = 3
+	s.addr = 1
+	s.computed = n
+	s.computed = 1
+	s.Exported = 1
+	s.ratio = 1
+}
+
+var (
+	_ = state{keyed: 1}
+	_ = state{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+)
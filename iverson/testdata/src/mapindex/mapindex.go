@@ -0,0 +1,28 @@
+package mapindex
+
+var table = map[bool]int{true: 1, false: 0}
+
+func lookup(b bool) int {
+	return table[b] // want "explicit"
+}
+
+func literal(b bool) float64 {
+	return map[bool]float64{true: 1, false: 0}[b] // want "explicit"
+}
+
+// Stores are not conversions, but any index of a map[bool]int is currently counted.
+func store(b bool) {
+	table[b] = 2 // want "explicit"
+}
+
+// the remaining functions must not be reported
+
+var names = map[bool]string{true: "yes", false: "no"}
+
+func name(b bool) string {
+	return names[b]
+}
+
+func slice(xs []int, i int) int {
+	return xs[i]
+}
@@ -0,0 +1,45 @@
+package roundtrip
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var table = map[bool]int{true: 1}
+
+func calls(a, b bool) bool {
+	x := btoi(a)              // want "explicit"
+	var y = table[b]          // want "explicit"
+	return x != 0 && 1 == (y) // want "round-trip" "round-trip"
+}
+
+func implicit(b bool) bool {
+	var n int
+	if b { // want "implicit"
+		n = 1
+	} else {
+		n = 0
+	}
+	return n > 0 // want "round-trip"
+}
+
+// the remaining comparisons must not be reported
+
+var global = btoi(true) // want "degenerate"
+
+func notRoundTrips(a bool, m int) bool {
+	x := btoi(a) // want "explicit"
+	return x == 2 || m == 0 || global == 1
+}
+
+func idents(b bool, p, q int) bool {
+	var n int
+	if b { // want "implicit"
+		n = p
+	} else {
+		n = q
+	}
+	return n == 0
+}
@@ -0,0 +1,121 @@
+package switchcase
+
+func tagless(b bool) int {
+	var x int
+	switch { // want "implicit"
+	case b:
+		x = 1
+	default:
+		x = 0
+	}
+	return x
+}
+
+func defaultFirst(b bool) int {
+	var x int
+	switch { // want "implicit"
+	default:
+		x = 0
+	case b:
+		x = 1
+	}
+	return x
+}
+
+func tagged(b bool) (x int) {
+	switch b { // want "implicit"
+	case true:
+		x = 1
+	case false:
+		x = 0
+	}
+	return
+}
+
+func taggedDefault(b bool) (x float64) {
+	switch b { // want "implicit"
+	case false:
+		x = 0
+	default:
+		x = 1
+	}
+	return
+}
+
+func init2(b bool) (x int) {
+	switch c := !b; c { // want "implicit"
+	case true:
+		x = 0
+	default:
+		x = 1
+	}
+	return
+}
+
+func notBool(n int) int {
+	var x int
+	switch n {
+	case 1:
+		x = 1
+	default:
+		x = 0
+	}
+	return x
+}
+
+func twoConds(a, b bool) int {
+	var x int
+	switch {
+	case a:
+		x = 1
+	case b:
+		x = 0
+	}
+	return x
+}
+
+func three(b bool) int {
+	var x int
+	switch b {
+	case true:
+		x = 1
+	case false:
+		x = 0
+	default:
+	}
+	return x
+}
+
+func notNumber(b bool) string {
+	var s string
+	switch {
+	case b:
+		s = "yes"
+	default:
+		s = "no"
+	}
+	return s
+}
+
+func fallsThrough(b bool) int {
+	var x int
+	switch {
+	case b:
+		x = 1
+		fallthrough
+	default:
+		x = 0
+	}
+	return x
+}
+
+func multiple(b, c bool) int {
+	var x int
+	switch {
+	case b, c:
+		x = 1
+	default:
+		x = 0
+	}
+	return x
+}
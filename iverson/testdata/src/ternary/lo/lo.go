@@ -0,0 +1,9 @@
+// Package lo has the generic helpers of popular utility modules.
+package lo
+
+func Ternary[T any](cond bool, a, b T) T {
+	if cond {
+		return a
+	}
+	return b
+}
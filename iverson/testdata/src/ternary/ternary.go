@@ -0,0 +1,64 @@
+package ternary
+
+import "example.com/testdata/ternary/lo"
+
+func If[T any](cond bool, then, els T) T {
+	if cond {
+		return then
+	}
+	return els
+}
+
+func ifInt(cond bool, then, els int) int {
+	if cond {
+		return then
+	}
+	return els
+}
+
+func local(b bool) int {
+	return If(b, 1, 0) // want "explicit"
+}
+
+func concrete(b bool, n int) int {
+	return ifInt(b, n, 0) // want "explicit"
+}
+
+func qualified(b bool) float64 {
+	return lo.Ternary(b, 1.0, 0) // want "explicit"
+}
+
+func instantiated(b bool) uint8 {
+	return lo.Ternary[uint8](b, 0, 1) // want "explicit"
+}
+
+func constant() int {
+	return If(true, 1, 0) // want "degenerate"
+}
+
+// the remaining functions must not be reported
+
+func strs(b bool) string {
+	return If(b, "yes", "no")
+}
+
+type helper struct{}
+
+func (helper) If(cond bool, then, els int) int {
+	return If(cond, then, els) // want "explicit"
+}
+
+func method(h helper, b bool) int {
+	return h.If(b, 1, 0)
+}
+
+func mixed(b bool, then int, els int64) int64 {
+	return pick(b, then, els)
+}
+
+func pick(cond bool, then int, els int64) int64 {
+	if cond {
+		return int64(then)
+	}
+	return els
+}
@@ -0,0 +1,33 @@
+package unsafeconv
+
+import "unsafe"
+
+func byteOf(b bool) byte {
+	return *(*byte)(unsafe.Pointer(&b)) // want "explicit"
+}
+
+type flag bool
+
+func intOf(f flag) int {
+	return int(*(*uint8)(unsafe.Pointer(&f))) // want "explicit"
+}
+
+func through(p *bool) uint8 {
+	return *(*uint8)(unsafe.Pointer(p)) // want "explicit"
+}
+
+// the remaining functions must not be reported
+
+func notBool(n int32) uint32 {
+	return *(*uint32)(unsafe.Pointer(&n))
+}
+
+func toBool(n uint8) bool {
+	return *(*bool)(unsafe.Pointer(&n))
+}
+
+func deref(p *int) int {
+	return *p
+}
+
+type T *int
@@ -0,0 +1,33 @@
+package iverson
+
+import "sync"
+
+// A Transform post-processes the findings of a package after detection and before they are reported,
+// to filter, enrich, or score them.
+type Transform func([]Finding) []Finding
+
+var (
+	transformsMu sync.Mutex
+	transforms   []Transform
+)
+
+// RegisterTransform adds t to the transforms ApplyTransforms runs, in the order registered.
+// The issue61915 command and Analyzer apply them to the findings of every package,
+// so a tool built on either can filter, enrich, or score what they report
+// by registering transforms from an init func, without changing the reporting code.
+func RegisterTransform(t Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms = append(transforms, t)
+}
+
+// ApplyTransforms runs the registered transforms over found and returns the result.
+func ApplyTransforms(found []Finding) []Finding {
+	transformsMu.Lock()
+	ts := transforms
+	transformsMu.Unlock()
+	for _, t := range ts {
+		found = t(found)
+	}
+	return found
+}
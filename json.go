@@ -0,0 +1,199 @@
+package main
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// jsonFinding is the -json record of a finding.
+type jsonFinding struct {
+	Record  string `json:"record"` // always "finding"
+	Package string `json:"package"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Kind    string `json:"kind"`
+	Form    string `json:"form"`
+	Func    string `json:"func"`
+	ID      string `json:"id"`
+
+	Severity  string `json:"severity"`
+	Type      string `json:"type,omitempty"`
+	Where     string `json:"where,omitempty"`
+	API       string `json:"api,omitempty"`
+	Usage     string `json:"usage,omitempty"`
+	Arity     int    `json:"arity,omitempty"`
+	Composed  int    `json:"composed,omitempty"`
+	Alloc     string `json:"alloc,omitempty"`
+	Values    string `json:"values,omitempty"`
+	Rewrite   string `json:"rewrite,omitempty"`
+	Proposed  string `json:"proposed,omitempty"`
+	Within    string `json:"within,omitempty"`
+	Cond      string `json:"cond,omitempty"`
+	SSA       string `json:"ssa,omitempty"`
+	Reach     string `json:"reach,omitempty"`
+	Build     string `json:"build,omitempty"`
+	GoVersion string `json:"go,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Callee    string `json:"callee,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Lines     int    `json:"lines,omitempty"`
+	Chars     int    `json:"chars,omitempty"`
+	Content   string `json:"content,omitempty"`
+	Shape     string `json:"shape"`
+}
+
+func newJSONFinding(pkg *packages.Package, f iverson.Finding) jsonFinding {
+	return jsonFinding{
+		Record:  "finding",
+		Package: pkg.ID,
+		File:    f.Pos.Filename,
+		Line:    f.Pos.Line,
+		Column:  f.Pos.Column,
+		Kind:    f.Kind,
+		Form:    f.Form,
+		Func:    f.Func,
+		ID:      f.ID,
+
+		Severity:  severity.of(f).String(),
+		Type:      f.Type,
+		Where:     f.Where,
+		API:       f.API,
+		Usage:     f.Usage,
+		Arity:     f.Arity,
+		Composed:  f.Composed,
+		Alloc:     f.Alloc,
+		Values:    f.Values,
+		Rewrite:   f.Rewrite,
+		Proposed:  f.Proposed,
+		Within:    f.Within,
+		Cond:      f.Cond,
+		SSA:       f.SSA,
+		Reach:     f.Reach,
+		Build:     f.Build,
+		GoVersion: f.GoVersion,
+		Owner:     f.Owner,
+		Callee:    f.Callee,
+		Module:    f.Module,
+		Lines:     f.Lines,
+		Chars:     f.Chars,
+		Content:   f.Content,
+		Shape:     f.Shape,
+	}
+}
+
+// finding is the Finding that f records, for report -from.
+// CalleePkg and the offset of the position are not recorded, so they are left empty.
+func (f jsonFinding) finding() iverson.Finding {
+	return iverson.Finding{
+		ID:      f.ID,
+		Content: f.Content,
+		Pos:     token.Position{Filename: f.File, Line: f.Line, Column: f.Column},
+		Kind:    f.Kind,
+		Form:    f.Form,
+		Func:    f.Func,
+		Type:    f.Type,
+
+		Where:     f.Where,
+		API:       f.API,
+		Usage:     f.Usage,
+		Arity:     f.Arity,
+		Composed:  f.Composed,
+		Alloc:     f.Alloc,
+		Values:    f.Values,
+		Rewrite:   f.Rewrite,
+		Proposed:  f.Proposed,
+		Within:    f.Within,
+		Shape:     f.Shape,
+		Cond:      f.Cond,
+		SSA:       f.SSA,
+		Reach:     f.Reach,
+		Build:     f.Build,
+		GoVersion: f.GoVersion,
+		Owner:     f.Owner,
+		Callee:    f.Callee,
+		Module:    f.Module,
+		Lines:     f.Lines,
+		Chars:     f.Chars,
+	}
+}
+
+// jsonSummary is the last -json record, with the counts of each kind of finding.
+type jsonSummary struct {
+	Record   string                    `json:"record"` // always "summary"
+	Packages []jsonPackage             `json:"packages"`
+	Total    map[string]int            `json:"total"`
+	Tests    map[string]int            `json:"total_in_tests,omitempty"` // findings in _test.go files, also in Total
+	By       map[string]map[string]int `json:"by,omitempty"`             // -by value to counts
+	Matrix   matrix                    `json:"matrix,omitempty"`         // with -matrix
+	Coverage *jsonCoverage             `json:"coverage,omitempty"`
+	InLoops  int                       `json:"alloc_in_loops,omitempty"` // findings with alloc "loop"
+	Removed  map[string]int            `json:"removed,omitempty"`        // findings in the -baseline gone this run
+	// findings in generated files, which are not in the other counts unless -include-generated
+	Generated map[string]int `json:"generated,omitempty"`
+	CallSites int            `json:"call_sites,omitempty"` // with -definitions, as in each package
+	FixAudit  *fixAudit      `json:"fix_audit,omitempty"`  // with -fix-audit
+	Repos     []jsonRepo     `json:"repos,omitempty"`      // with corpus
+	// files left out by -dedupe-identical-files, with the counts of the findings in them
+	Duplicates *jsonDuplicates   `json:"duplicates,omitempty"`
+	Failed     map[string]string `json:"failed,omitempty"` // targets or modules that failed to load, with why
+}
+
+// jsonDuplicates are the files left out of the counts by -dedupe-identical-files.
+type jsonDuplicates struct {
+	Files  int            `json:"files"`
+	Counts map[string]int `json:"counts"`
+}
+
+// jsonRepo is the rollup of the packages analyzed in the modules of one repository, with corpus.
+type jsonRepo struct {
+	Repo    string         `json:"repo"`    // URL, or the path of its root module
+	Modules []string       `json:"modules"` // as path@version
+	Counts  map[string]int `json:"counts"`
+	Lines   int            `json:"lines"`         // of the files analyzed
+	Density float64        `json:"density"`       // implicit and explicit findings per 1000 lines
+	Top     []jsonTop      `json:"top,omitempty"` // packages with the most of them, most first
+}
+
+// jsonTop is a package of a jsonRepo with how many implicit and explicit findings it has.
+type jsonTop struct {
+	Path string `json:"path"`
+	All  int    `json:"all"`
+}
+
+// jsonPackageRecord is the -json record of a package with -stream, after those of its findings.
+type jsonPackageRecord struct {
+	Record string `json:"record"` // always "package"
+	jsonPackage
+}
+
+type jsonPackage struct {
+	ID     string         `json:"id"`
+	Path   string         `json:"path"`
+	Name   string         `json:"name"`
+	Module string         `json:"module,omitempty"` // path@version, or just the path of the main module
+	Counts map[string]int `json:"counts"`
+	Tests  map[string]int `json:"in_tests,omitempty"` // findings in _test.go files, also in Counts
+	// calls of bracket funcs, explicit or degenerate, with -definitions, to set against the definitions in Counts
+	CallSites int `json:"call_sites,omitempty"`
+}
+
+func newJSONPackage(pkg *packages.Package, counts map[string]int) jsonPackage {
+	p := jsonPackage{ID: pkg.ID, Path: pkg.PkgPath, Name: pkg.Name, Counts: counts}
+	if m := pkg.Module; m != nil {
+		p.Module = m.Path
+		if m.Version != "" {
+			p.Module += "@" + m.Version
+		}
+	}
+	return p
+}
+
+// jsonCoverage is the number of packages analyzed within the -budget.
+type jsonCoverage struct {
+	Covered  int `json:"covered"`
+	Packages int `json:"packages"`
+}
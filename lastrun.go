@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// lastRun is the state kept by -since-last-run: the findings of the previous run
+// over the same modules.
+type lastRun struct {
+	file    string
+	prev    map[string]bool // IDs
+	moves   *moves
+	records []string
+}
+
+// moves matches findings with new IDs to those of an earlier run, or a baseline,
+// with the same Content in another package, taken to have moved there with their file,
+// so that they are reported as neither added nor removed.
+// An earlier finding found again this run accounts for no move, so that copied code is still new,
+// and each accounts for at most one.
+// Whether one is found again is only known once its package is analyzed,
+// so a finding that moved from a package analyzed later in the run is not matched.
+type moves struct {
+	from     map[string][]movedFrom // by Content
+	loaded   map[string]bool        // the packages loaded this run
+	analyzed map[string]bool        // those analyzed so far
+	seen     map[string]bool        // IDs found so far this run
+	moved    map[string]bool        // IDs of the earlier findings that moved
+}
+
+// A movedFrom is an earlier finding, by its ID and its package.
+type movedFrom struct {
+	id, pkg string
+}
+
+func newMoves() *moves {
+	return &moves{from: map[string][]movedFrom{}, loaded: map[string]bool{}, analyzed: map[string]bool{}, seen: map[string]bool{}, moved: map[string]bool{}}
+}
+
+// earlier records an earlier finding.
+func (m *moves) earlier(id, content, pkg string) {
+	if content != "" {
+		m.from[content] = append(m.from[content], movedFrom{id, pkg})
+	}
+}
+
+// analyze records that pkg was analyzed, finding found.
+func (m *moves) analyze(pkg string, found []iverson.Finding) {
+	m.analyzed[pkg] = true
+	for _, f := range found {
+		m.seen[f.ID] = true
+	}
+}
+
+// match returns the earlier finding that f in pkg moved from, if any, logging the move.
+func (m *moves) match(pkg string, f iverson.Finding) (movedFrom, bool) {
+	from := m.from[f.Content]
+	i := slices.IndexFunc(from, func(e movedFrom) bool {
+		return e.pkg != pkg && !m.seen[e.id] && (!m.loaded[e.pkg] || m.analyzed[e.pkg])
+	})
+	if i < 0 {
+		return movedFrom{}, false
+	}
+	e := from[i]
+	slog.Info("moved finding", "pos", f.Pos, "id", f.ID, "pkg", pkg, "from", e.pkg)
+	m.from[f.Content] = slices.Delete(from, i, i+1)
+	m.moved[e.id] = true
+	return e, true
+}
+
+// stateDir returns the XDG state directory for this tool.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "issue61915"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "issue61915"), nil
+}
+
+// loadLastRun reads the IDs recorded by the previous run over the modules of ps.
+// If there was none, every finding is new.
+func loadLastRun(ps []*packages.Package) (*lastRun, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	var mods []string
+	for _, pkg := range ps {
+		if pkg.Module != nil && !slices.Contains(mods, pkg.Module.Path) {
+			mods = append(mods, pkg.Module.Path)
+		}
+	}
+	slices.Sort(mods)
+	key := "no-module"
+	if len(mods) > 0 {
+		key = url.PathEscape(strings.Join(mods, "+"))
+	}
+	lr := &lastRun{file: filepath.Join(dir, key+".ids"), prev: map[string]bool{}, moves: newMoves()}
+	for _, pkg := range ps {
+		lr.moves.loaded[pkg.PkgPath] = true
+	}
+
+	f, err := os.Open(lr.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return lr, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// each line is the ID, Content, and package of a finding,
+	// or just the ID if it was recorded before Content was
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		lr.prev[fields[0]] = true
+		if len(fields) == 3 {
+			lr.moves.earlier(fields[0], fields[1], fields[2])
+		}
+	}
+	return lr, sc.Err()
+}
+
+// filter records the findings in pkg and returns those not found by the previous run,
+// nor moved from another package, as by moves.
+func (lr *lastRun) filter(pkg *packages.Package, found []iverson.Finding) []iverson.Finding {
+	lr.moves.analyze(pkg.PkgPath, found)
+	for _, f := range found {
+		lr.records = append(lr.records, f.ID+" "+f.Content+" "+pkg.PkgPath)
+	}
+	return slices.DeleteFunc(found, func(f iverson.Finding) bool {
+		if lr.prev[f.ID] {
+			return true
+		}
+		_, moved := lr.moves.match(pkg.PkgPath, f)
+		return moved
+	})
+}
+
+// save records the findings of this run for the next.
+func (lr *lastRun) save() error {
+	if err := os.MkdirAll(filepath.Dir(lr.file), 0o755); err != nil {
+		return err
+	}
+	slices.Sort(lr.records)
+	var b strings.Builder
+	for _, r := range lr.records {
+		b.WriteString(r)
+		b.WriteString("\n")
+	}
+	return os.WriteFile(lr.file, []byte(b.String()), 0o644)
+}
@@ -1,230 +1,899 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"go/ast"
 	"go/token"
-	"go/types"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+var (
+	verbose  = flag.Bool("v", false, "verbose: log load and analysis timing")
+	logJSON  = flag.Bool("log-json", false, "write log records as JSON lines")
+	jsonOut  = flag.Bool("json", false, "write a JSON record for each finding and then one summarizing the counts to stdout, instead of the text summary; the same as -format=json")
+	format   = flag.String("format", "text", "write the results to stdout as `format`: text, json, sarif, or csv")
+	totals   = flag.String("csv-totals", "", "also write the counts of each kind of finding in each package to this CSV `file`")
+	viz      = flag.String("viz", "", "write the counts by package hierarchy to stdout as `format`, dot for Graphviz or treemap for JSON in the format of d3.hierarchy, instead of the text summary")
+	findings = flag.String("findings", "", "write each finding to stderr as `format`: text for a log record, which is JSON with -log-json, json for the record of -format=json, or none; by default text with -v and otherwise none")
+	htmlOut  = flag.String("html", "", "also write a self-contained HTML report of the findings in each package and file, with the source of each, to this `file`")
+
+	intAsBool = flag.Bool("int-as-bool", false, "also report unexported integer struct fields only ever set to 0 or 1")
+	probable  = flag.Bool("probable-helpers", false, "also report funcs named like bool to number helpers, like b2i or BoolToInt, whose signatures are not exactly those of bracket funcs")
+	defs      = flag.Bool("definitions", false, "also report the declarations of bracket funcs and methods and the bracket func literals, and summarize each package by how many it defines and how many calls of bracket funcs it makes")
+	generated = flag.Bool("include-generated", false, "also report findings in generated files, with a // Code generated ... DO NOT EDIT. comment, rather than only counting them")
+	prefilter = flag.Bool("prefilter", false, "skip inspecting files without any token that could start most findings, like x = 1, n++, map[bool], or the name of a bracket func, which misses implicit ifs choosing between other numbers, like x = lo")
+	overlaps  = flag.String("overlaps", "keep", "keep every finding, collapse those nested in another into the one that takes precedence: an if over the conversions in it, and a conversion over degenerate ones in it, or mark them with the ID of that one, keeping them all")
+	returns   = flag.Bool("returns", false, "also count ifs returning a number by a bool, like the bodies of bracket funcs")
+	imported  = flag.Bool("imported", false, "also count calls of bracket funcs and methods from other packages, like pkg.Btoi(b)")
+	archive   = flag.String("archive", "", "load the patterns, or ./..., from the source in this .zip, .tar, .tar.gz, or .tgz `file`, such as a module zip")
+	targets   = flag.String("targets-file", "", "analyze the package pattern, directory, or module root on each line of this `file`, or stdin if -, instead of the patterns, skipping any that fail to load")
+	depsOf    = flag.String("deps-of", "", "analyze every package in the modules required by this go.mod `file`, at exactly the required versions, instead of the patterns")
+	tags      = flag.String("tags", "", "load the packages with the files of this comma separated `list` of build tags, as go build -tags does")
+	platforms = flag.String("platforms", "", "load and analyze the patterns for each GOOS/GOARCH in this comma separated `list`, like linux/amd64,windows/arm64, analyzing each file once, for the first that has it")
+	withTests = flag.Bool("tests", false, "also load and analyze the tests of each package, as go test builds them, analyzing each file once")
+	noTests   = flag.Bool("no-test-files", false, "do not count findings in _test.go files, which are otherwise counted apart as well")
+	noSerial  = flag.Bool("no-serialization", false, "do not count findings in methods like String or MarshalJSON that only encode bools for output")
+	sinceLast = flag.Bool("since-last-run", false, "only report findings that the last run with this flag over the same modules did not, keeping their IDs in $XDG_STATE_HOME/issue61915")
+	baseFile  = flag.String("baseline", "", "only report findings that the snapshot in this `file` does not have, and log those it has that are gone, as with -since-last-run but for any snapshot")
+	writeBase = flag.Bool("write-baseline", false, "write the findings of this run to the -baseline file, replacing it, instead of comparing against it")
+	fix       = flag.Bool("fix", false, "rewrite each implicit if or switch setting a variable to 1 or 0 by a bool in the main module into an assignment of a call of a helper like func b2i(b bool) int, and each if b { n++ } into n += b2i(b), adding one to each package without, and write the files back")
+	gather    = flag.Bool("consolidate", false, "collect the helpers of the main module from bools to numbers, if it declares more than one, into one func per result type in a new internal/booleans package, like booleans.ToInt, rewriting their uses and deleting the unexported ones no longer used, and write the files back, or print the edits with -diff")
+	auditFix  = flag.Bool("fix-audit", false, "run every check of -fix on each implicit finding without writing anything, and summarize how many it would fix and how many need attention, by why, such as comments, line directives, read-only or vendored files, or an init statement; the condition is evaluated once either way, so its side effects and later uses need no check")
+	diffOut   = flag.Bool("diff", false, "print the edits -fix would make to stdout as a unified diff, for git apply, instead of making them, and write the results to stderr")
+	workers   = flag.Int("concurrency", runtime.GOMAXPROCS(0), "analyze up to this many packages at once")
+	budget    = flag.Duration("budget", 0, "stop analyzing, smallest packages first, once the run has taken this `duration`, and report the coverage")
+	deps      = flag.Bool("deps", false, "also analyze every package imported by the patterns and, if any are main packages, report which findings are reachable from them")
+	withSSA   = flag.Bool("ssa", false, "build SSA to check that each implicit if sets a local variable held in a register rather than memory that could be aliased")
+	ownersOf  = flag.String("codeowners", "", "attribute findings to their owners by this CODEOWNERS `file`")
+	by        = flag.String("by", "", "also break down the counts by `key`: alloc (of a literal, in a loop), api (in exported funcs from bools to numbers), build (constraint of the file), composed (how many conversions in one expression), cond (reused after conversion or consumed), file (by name, with its package), func (enclosing function or method, with its package), go (language version), lines (spanned by the source, 1, 2, 3, or 4+), module (of the package, with its version if not the main module, and the default for corpus), owner (by -codeowners), reach (from mains with -deps), rewrite (of implicit ifs), ssa (with -ssa), test (or prod package), type, usage (as an index of how many bools, in indexing arithmetic, or in serialization methods), values (of the then and else branches of implicit ifs and ternary calls, as 0, 1, c for another constant, or x for a variable), or where (in a deferred or go closure)")
+	stream    = flag.Bool("stream", false, "print the summary of each package to stdout as soon as it is analyzed, in the order analyzed, rather than all at once at the end; with -format=json, write a package record after its findings, and with csv, flush its rows")
+	matrixOut = flag.Bool("matrix", false, "also print a table of the counts of each kind of finding for every value of every -by key")
+	confFile  = flag.String("config", "", "read the settings of flags not on the command line, and the patterns if none are, from this iverson.toml or .iverson.yaml `file` instead of the one in the working directory, or none")
+	statusOut = flag.String("status-file", "", "also write the exit reason, counts, -fail-on threshold, errors, and timing of the run as JSON to this `file`, whatever the -format and even if the run fails")
+
+	severity  = defaultSeverities()
+	failLevel failOn
+	pkgFilter pkgRegexp
+	excludes  pkgPatterns
+
+	alwaysHelpers = funcNames{}
+	neverHelpers  = funcNames{}
 )
 
+func init() {
+	flag.Var(severity, "severity", "comma separated kind=info|warning|error `list` overriding the severity of each kind of finding, where kind/form=level, like explicit/literal=error for reads of map literals, overrides it for the findings of one form")
+	flag.Var(&failLevel, "fail-on", "exit with an error if any finding has at least this `severity`")
+	flag.Var(&pkgFilter, "pkg-filter", "only analyze packages whose import path matches `regexp`, or does not match if it starts with !")
+	flag.Var(&excludes, "exclude", "do not analyze packages whose import path matches any of this comma separated `list` of patterns, where ... matches any string, as with go list")
+	flag.Var(alwaysHelpers, "helpers", "count every call of the funcs and methods in this comma separated `list` as an explicit conversion, whatever their signatures, named in full like example.com/m.btoi or (*example.com/m.T).Btoi")
+	flag.Var(neverHelpers, "not-helpers", "never count the calls of the funcs and methods in this comma separated `list`, named as for -helpers, though they look like bracket funcs or ternary helpers")
+}
+
 func main() {
-	log.SetFlags(0)
+	runAnalyzer()
 	flag.Parse()
+	// the configuration is read before the logger is set up, as it may set -v or -log-json
+	conf, confPatterns, err := applyConfig()
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// timestamps only make diffing the output of two runs harder
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if *logJSON {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	err := Main(ctx, flag.Args())
+	if conf != "" {
+		slog.Debug("read configuration", "file", conf)
+	}
+	status.start = time.Now()
+	switch args := flag.Args(); {
+	case err != nil:
+		// the configuration could not be applied
+	case len(args) > 0 && args[0] == "selftest":
+		err = Selftest(ctx, args[1:])
+	case len(args) > 0 && args[0] == "report":
+		err = Report(args[1:])
+	case len(args) > 0 && args[0] == "corpus":
+		err = Corpus(ctx, args[1:])
+	case len(args) > 0 && args[0] == "serve":
+		err = Serve(ctx, args[1:])
+	default:
+		if len(args) == 0 && *targets == "" && *depsOf == "" {
+			args = confPatterns
+		}
+		err = Main(ctx, args)
+	}
+	if *statusOut != "" {
+		if serr := writeStatus(*statusOut, err); serr != nil {
+			slog.Error("could not write status file", "file", *statusOut, "err", serr)
+			if err == nil {
+				os.Exit(1)
+			}
+		}
+	}
 	if err != nil {
-		log.Fatal(err)
+		var lerr *iverson.LoadError
+		switch {
+		case errors.As(err, &lerr) && *logJSON:
+			slog.Error(err.Error(), "errors", lerr.Errors)
+		case errors.As(err, &lerr):
+			for _, e := range lerr.Errors {
+				slog.Error("load error", "pkg", e.Package, "pos", e.Pos, "kind", e.Kind, "msg", e.Msg)
+			}
+			slog.Error(err.Error())
+		default:
+			slog.Error(err.Error())
+		}
+		os.Exit(1)
 	}
 }
 
 func Main(ctx context.Context, pattern []string) error {
-	ps, err := Packages(ctx, pattern)
+	groupBy, err := grouping(*by)
 	if err != nil {
 		return err
 	}
-
-	var out []string
-	totalImplicit, totalExplicit := 0, 0
-	for _, pkg := range ps {
-		implicit, explicit := Find(pkg)
-		if implicit+explicit > 0 {
-			totalImplicit += implicit
-			totalExplicit += explicit
-			out = append(out, fmt.Sprintf("%s: %d implicit, %d explicit; all %d", pkg.ID, implicit, explicit, implicit+explicit))
+	outFormat, err := outputFormat()
+	if err != nil {
+		return err
+	}
+	if *overlaps != "keep" && *overlaps != "collapse" && *overlaps != "mark" {
+		return fmt.Errorf("unknown -overlaps %q: want keep, collapse, or mark", *overlaps)
+	}
+	if *workers < 1 {
+		return fmt.Errorf("-concurrency must be at least 1, not %d", *workers)
+	}
+	plats, err := parsePlatforms(*platforms)
+	if err != nil {
+		return err
+	}
+	if *writeBase && *baseFile == "" {
+		return errors.New("-write-baseline requires -baseline")
+	}
+	var base *baseline
+	if *baseFile != "" {
+		base, err = loadBaseline(*baseFile, *writeBase)
+		if err != nil {
+			return err
 		}
 	}
-
-	for _, line := range out {
-		fmt.Println(line)
+	stats := runStats{start: time.Now()}
+	defer stats.log()
+	status.stats = &stats
+	dir := ""
+	switch {
+	case corpusModules != nil && (*targets != "" || *depsOf != "" || *archive != ""):
+		return errors.New("corpus cannot be used with -targets-file, -deps-of, or -archive")
+	case *targets != "" && (*depsOf != "" || *archive != "" || len(pattern) > 0):
+		return errors.New("-targets-file takes no patterns and cannot be used with -deps-of or -archive")
+	case *depsOf != "" && (*archive != "" || len(pattern) > 0):
+		return errors.New("-deps-of takes no patterns and cannot be used with -archive")
+	case *depsOf != "":
+		ws, patterns, cleanup, err := depsWorkspace(ctx, *depsOf)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		slog.Debug("created deps workspace", "go.mod", *depsOf, "dir", ws, "modules", len(patterns))
+		dir, pattern = ws, patterns
+	case *archive != "":
+		root, cleanup, err := extractArchive(*archive)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		slog.Debug("extracted archive", "archive", *archive, "dir", root)
+		dir = root
+		if len(pattern) == 0 {
+			pattern = []string{"./..."}
+		}
 	}
-	if len(ps) > 1 {
-		fmt.Printf("\nTOTAL: %d implicit, %d explicit; all %d\n", totalImplicit, totalExplicit, totalImplicit+totalExplicit)
+	loadAll := func(load iverson.LoadOptions) ([]*packages.Package, error) {
+		switch {
+		case corpusModules != nil:
+			return loadCorpus(ctx, corpusModules, load)
+		case *targets != "":
+			list, err := readTargets(*targets)
+			if err != nil {
+				return nil, err
+			}
+			return loadTargets(ctx, list, load)
+		}
+		return iverson.Packages(ctx, dir, pattern, load)
 	}
-	return nil
-}
+	load := iverson.LoadOptions{Deps: *deps, Tests: *withTests, Tags: *tags}
+	var ps []*packages.Package
+	if plats == nil {
+		ps, err = loadAll(load)
+		if err != nil {
+			return err
+		}
+	}
+	for _, platform := range plats {
+		load.Platform = platform
+		more, err := loadAll(load)
+		if err != nil {
+			return fmt.Errorf("%s: %w", platform, err)
+		}
+		ps = append(ps, more...)
+	}
+	stats.loaded()
 
-func Packages(ctx context.Context, pattern []string) ([]*packages.Package, error) {
-	cfg := &packages.Config{
-		Context: ctx,
+	mods := newModuleIndex(ps)
+	var owners *codeowners
+	if *ownersOf != "" {
+		owners, err = parseCodeowners(*ownersOf)
+		if err != nil {
+			return err
+		}
+	}
+	if base != nil {
+		base.load(ps)
+	}
+	var last *lastRun
+	if *sinceLast {
+		last, err = loadLastRun(ps)
+		if err != nil {
+			return err
+		}
+	}
+	var reach map[string]bool
+	if *deps {
+		reach = reachable(ps)
+		ps = all(ps)
+	}
+	if *withTests {
+		ps = iverson.DedupeTests(ps)
+	}
+	if plats != nil {
+		ps = iverson.DedupePlatforms(ps)
+	}
+	var dups map[string]string
+	if corpusModules != nil && dedupeFiles {
+		dups = duplicateFiles(ps)
+	}
+	// the order to analyze ps in; the output is in the order they were loaded
+	order := make([]int, len(ps))
+	for i := range order {
+		order[i] = i
+	}
+	if *budget > 0 {
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(pkgSize(ps[a]), pkgSize(ps[b]))
+		})
+	}
+	// the result of analyzing a package
+	type result struct {
+		skip      string // why the package was not analyzed, if it was not
+		found     []iverson.Finding
+		generated map[string]int // counts of the findings dropped from generated files
+		duplicate map[string]int // and from duplicate files, with -dedupe-identical-files
+		detectors map[string]time.Duration
+		elapsed   time.Duration
+		done      chan struct{}
+	}
+	// analyze finds the findings in pkg and attributes them in r,
+	// with the time spent in each detector,
+	// or returns the error of ctx if it is done before all the files of pkg are inspected.
+	// It is run for up to -concurrency packages at once.
+	analyze := func(pkg *packages.Package, r *result) error {
+		detectors := map[string]time.Duration{}
+		r.detectors = detectors
+		var found []iverson.Finding
+		var err error
+		stats.timed(detectors, "find", func() {
+			found, err = iverson.FindContext(ctx, pkg, iverson.Options{Imported: *imported, Returns: *returns, Prefilter: *prefilter, Helpers: alwaysHelpers, NotHelpers: neverHelpers})
+		})
+		if err != nil {
+			return err
+		}
+		if !*generated {
+			found, r.generated = iverson.DropGenerated(pkg.Syntax, pkg.Fset, found)
+		}
+		if dups != nil {
+			found, r.duplicate = dropDuplicates(found, dups)
+		}
+		if *noTests {
+			found = slices.DeleteFunc(found, inTestFile)
+		}
+		if *intAsBool {
+			stats.timed(detectors, "int-as-bool", func() {
+				found = append(found, iverson.FindIntAsBool(pkg)...)
+			})
+		}
+		if *probable {
+			stats.timed(detectors, "probable-helpers", func() {
+				found = append(found, iverson.FindProbableHelpers(pkg)...)
+			})
+		}
+		if *defs {
+			stats.timed(detectors, "definitions", func() {
+				found = append(found, iverson.FindDefinitions(pkg)...)
+			})
+		}
+		switch *overlaps {
+		case "collapse":
+			stats.timed(detectors, "overlaps", func() {
+				found = iverson.CollapseOverlaps(found)
+			})
+		case "mark":
+			stats.timed(detectors, "overlaps", func() {
+				iverson.MarkOverlaps(found)
+			})
+		}
+		if *noSerial {
+			found = slices.DeleteFunc(found, func(f iverson.Finding) bool {
+				return f.Usage == "serialization"
+			})
+		}
+		mods.attribute(found)
+		if reach != nil {
+			for i := range found {
+				if reach[pkg.ID] {
+					found[i].Reach = "reachable"
+				} else {
+					found[i].Reach = "unreachable"
+				}
+			}
+		}
+		if *withSSA {
+			stats.timed(detectors, "ssa", func() {
+				verifySSA(pkg, found)
+			})
+		}
+		if owners != nil {
+			owners.attribute(found)
+		}
+		r.found = iverson.ApplyTransforms(found)
+		return nil
+	}
+	// each package is analyzed by a worker in the order to analyze them,
+	// and its findings are reported once it and those before it are done,
+	// so the results are the same whatever the -concurrency
+	results := make([]result, len(ps))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+	jobs := make(chan int)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(jobs)
+		for _, i := range order {
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for range *workers {
+		go func() {
+			for i := range jobs {
+				r, pkg := &results[i], ps[i]
+				switch {
+				case len(pkg.Syntax) == 0:
+					r.skip = "no syntax"
+				case !pkgFilter.match(pkg.PkgPath) || excludes.match(pkg.PkgPath):
+					r.skip = "filtered"
+				case *budget > 0 && time.Since(stats.start) >= *budget:
+					r.skip = "budget"
+				case ctx.Err() != nil:
+					r.skip = "interrupted"
+				default:
+					start := time.Now()
+					err := analyze(pkg, r)
+					r.elapsed = time.Since(start)
+					if err != nil {
+						r.found, r.generated, r.duplicate, r.skip = nil, nil, nil, "interrupted"
+					}
+				}
+				close(r.done)
+			}
+		}()
+	}
 
-		Mode: packages.NeedTypesInfo | packages.NeedTypes | packages.NeedSyntax | packages.NeedFiles,
+	var fixes *fixer
+	if *fix || *diffOut || *auditFix || *gather {
+		fixes = newFixer()
 	}
-	ps, err := packages.Load(cfg, pattern...)
-	if err != nil {
-		return nil, err
+	out := io.Writer(os.Stdout)
+	if *diffOut {
+		out = os.Stderr
+	}
+	rep := newReporter(out, outFormat, groupBy, len(ps))
+	rep.dups = dups
+	eligible, covered := 0, 0
+	interrupted := false
+	for _, i := range order {
+		pkg, r := ps[i], &results[i]
+		<-r.done
+		if r.skip != "" {
+			slog.Debug("skipping package", "pkg", pkg.ID, "reason", r.skip)
+			if r.skip == "budget" || r.skip == "interrupted" {
+				eligible++
+			}
+			interrupted = interrupted || r.skip == "interrupted"
+			continue
+		}
+		eligible++
+		covered++
+		for kind, n := range r.generated {
+			rep.generated[kind] += n
+		}
+		for kind, n := range r.duplicate {
+			rep.duplicate[kind] += n
+		}
+		found := r.found
+		if last != nil {
+			found = last.filter(pkg, found)
+		}
+		if base != nil {
+			found = base.filter(pkg, found)
+		}
+		counts, err := rep.add(i, pkg, found)
+		if err != nil {
+			return err
+		}
+		if fixes != nil && !*gather {
+			if err := fixes.add(pkg, found); err != nil {
+				return err
+			}
+		}
+		slog.Debug("analyzed package", "pkg", pkg.ID, "files", len(pkg.Syntax), "counts", counts, "elapsed", r.elapsed, "detectors", r.detectors)
+		stats.packages++
+		stats.files += len(pkg.Syntax)
+	}
+	if *budget > 0 {
+		if covered < eligible {
+			slog.Warn("budget exhausted", "budget", *budget, "covered", covered, "packages", eligible)
+		}
+		rep.coverage = &jsonCoverage{Covered: covered, Packages: eligible}
+	}
+	if interrupted {
+		// report the packages analyzed in full, but keep the last run, any baseline, and the files to -fix
+		// rather than replace them with a part of this one
+		slog.Warn("interrupted", "covered", covered, "packages", eligible)
+		rep.coverage = &jsonCoverage{Covered: covered, Packages: eligible}
+		if err := rep.write(); err != nil {
+			return err
+		}
+		return fmt.Errorf("interrupted: %w", ctx.Err())
+	}
+	if last != nil {
+		if err := last.save(); err != nil {
+			return err
+		}
+	}
+	if *gather {
+		if err := fixes.consolidate(ps); err != nil {
+			return err
+		}
 	}
-	if packages.PrintErrors(ps) > 0 {
-		return nil, fmt.Errorf("could not load packages")
+	if *auditFix {
+		a, err := fixes.audit()
+		if err != nil {
+			return err
+		}
+		rep.audit = a
+	} else if fixes != nil {
+		write := fixes.write
+		if *diffOut {
+			write = func() error { return fixes.diff(os.Stdout) }
+		}
+		if err := write(); err != nil {
+			return err
+		}
 	}
-	if len(ps) == 0 {
-		return nil, fmt.Errorf("no packages to load")
+	if base != nil {
+		if err := base.save(); err != nil {
+			return err
+		}
+		if !base.write {
+			rep.removed = map[string]int{}
+			for _, p := range base.removed() {
+				slog.Info("removed finding", "pos", p.f.Pos, "kind", p.f.Kind, "id", p.f.ID, "pkg", p.pkg)
+				rep.removed[p.f.Kind]++
+			}
+		}
 	}
-	return ps, nil
+	return rep.write()
 }
 
-type counter struct {
-	pkg                *packages.Package
-	implicit, explicit int
+// outputFormat returns the -format, checking that it, any -viz, and -findings are known.
+func outputFormat() (string, error) {
+	outFormat := *format
+	if *jsonOut {
+		outFormat = "json"
+	}
+	switch outFormat {
+	case "text", "json", "sarif", "csv":
+	default:
+		return "", fmt.Errorf("unknown -format %q: want text, json, sarif, or csv", outFormat)
+	}
+	switch {
+	case *viz != "" && *viz != "dot" && *viz != "treemap":
+		return "", fmt.Errorf("unknown -viz %q: want dot or treemap", *viz)
+	case *viz != "" && outFormat != "text":
+		return "", fmt.Errorf("-viz cannot be used with -format=%s", outFormat)
+	case *stream && *viz != "":
+		return "", errors.New("-stream cannot be used with -viz")
+	case *stream && outFormat == "sarif":
+		// SARIF is a single document
+		return "", errors.New("-stream cannot be used with -format=sarif")
+	}
+	if *auditFix && (*fix || *diffOut) {
+		return "", errors.New("-fix-audit cannot be used with -fix or -diff")
+	}
+	if *gather && (*fix || *auditFix) {
+		return "", errors.New("-consolidate cannot be used with -fix or -fix-audit")
+	}
+	switch *findings {
+	case "", "text", "json", "none":
+	default:
+		return "", fmt.Errorf("unknown -findings %q: want text, json, or none", *findings)
+	}
+	return outFormat, nil
 }
 
-func newCounter(pkg *packages.Package) *counter {
-	return &counter{pkg: pkg}
-}
+// collapseMin is the least number of consecutive findings with the same shape in a file
+// that the text log reports as one record.
+const collapseMin = 3
 
-func (c *counter) inspect(n ast.Node) bool {
-	hit := false
-	switch n := n.(type) {
-	case *ast.IfStmt:
-		// if-else statement whose branches only set a number
-		if PotentialIversonIf(c.pkg, n) {
-			c.implicit++
-			hit = true
-		} else {
-			// we need to manually scan the blocks and expressions to avoid false positives in else-if's
-			c.recurOnIf(n)
-			return false
+// logFindings logs a record for each of the findings in pkg.
+// Unless logging JSON, runs of at least collapseMin structurally identical findings that repeat each other,
+// as in generated tables, are logged once with the number of repeats and the last position.
+func logFindings(pkg *packages.Package, found []iverson.Finding) {
+	for i := 0; i < len(found); {
+		f := found[i]
+		j := i + 1
+		for j < len(found) && repeats(f, found[j]) {
+			j++
 		}
-
-	case *ast.CallExpr:
-		// calling a func(~number) ~bool
-		_, ok := n.Fun.(*ast.SelectorExpr)
-		if !ok && IsBracketFunc(c.pkg.TypesInfo.TypeOf(n.Fun)) {
-			c.explicit++
-			hit = true
+		attrs := []any{"pos", f.Pos, "kind", f.Kind, "severity", severity.of(f), "pkg", pkg.PkgPath, "name", pkg.Name, "func", f.Func, "id", f.ID, "callee", f.Callee, "module", f.Module, "where", f.Where, "api", f.API, "usage", f.Usage, "arity", f.Arity, "composed", f.Composed, "alloc", f.Alloc, "values", f.Values, "rewrite", f.Rewrite, "cond", f.Cond, "ssa", f.SSA, "reach", f.Reach, "build", f.Build, "go", f.GoVersion, "owner", f.Owner, "lines", f.Lines, "chars", f.Chars, "proposed", f.Proposed, "within", f.Within}
+		if *logJSON {
+			slog.Info("finding", append(attrs, "shape", f.Shape)...)
+			i++
+			continue
 		}
-
-	case *ast.IndexExpr:
-		// reading from a map[~bool]~number
-		if IsMapBracket(c.pkg.TypesInfo.TypeOf(n.X)) {
-			c.explicit++
-			hit = true
+		if j-i < collapseMin {
+			slog.Info("finding", attrs...)
+			i++
+			continue
 		}
+		slog.Info("finding", append(attrs, "repeat", j-i, "last", found[j-1].Pos)...)
+		i = j
 	}
-	if hit {
-		log.Print(c.pkg.Fset.Position(n.Pos()))
-	}
-	return true
 }
 
-func (c *counter) recurOnIf(n *ast.IfStmt) {
-	if n.Init != nil {
-		ast.Inspect(n.Init, c.inspect)
+// repeats reports whether a and b are in the same file and differ only in position, ID, Proposed, and Within.
+func repeats(a, b iverson.Finding) bool {
+	if a.Pos.Filename != b.Pos.Filename {
+		return false
 	}
-	ast.Inspect(n.Cond, c.inspect)
-	ast.Inspect(n.Body, c.inspect)
-	switch Else := n.Else.(type) {
-	case nil:
-	case *ast.IfStmt:
-		c.recurOnIf(Else)
-	case *ast.BlockStmt:
-		ast.Inspect(Else, c.inspect)
+	a.Pos, a.End, a.ID, a.Content, a.Lines, a.Chars, a.Proposed, a.Within = token.Position{}, token.Position{}, "", "", 0, 0, "", ""
+	b.Pos, b.End, b.ID, b.Content, b.Lines, b.Chars, b.Proposed, b.Within = token.Position{}, token.Position{}, "", "", 0, 0, "", ""
+	return a == b
+}
+
+// grouping returns the function computing the -by key of a finding.
+func grouping(by string) (func(*packages.Package, iverson.Finding) string, error) {
+	switch by {
+	case "":
+		return nil, nil
+	case "ssa":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.SSA, "other")
+		}, nil
+	case "type":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Type, "unknown")
+		}, nil
+	case "go":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.GoVersion, "unknown")
+		}, nil
+	case "owner":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Owner, "unowned")
+		}, nil
+	case "reach":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Reach, "other")
+		}, nil
+	case "rewrite":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Rewrite, "other")
+		}, nil
+	case "test":
+		return func(pkg *packages.Package, _ iverson.Finding) string {
+			if iverson.IsTest(pkg) {
+				return "test"
+			}
+			return "prod"
+		}, nil
+	case "usage":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			switch f.Usage {
+			case "":
+				return "other"
+			case "index":
+				return fmt.Sprintf("%s/%d", f.Usage, f.Arity)
+			}
+			return f.Usage
+		}, nil
+	case "alloc":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Alloc, "none")
+		}, nil
+	case "build":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Build, "none")
+		}, nil
+	case "lines":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			switch {
+			case f.Lines == 0:
+				return "unknown"
+			case f.Lines >= 4:
+				return "4+"
+			}
+			return strconv.Itoa(f.Lines)
+		}, nil
+	case "module":
+		return func(pkg *packages.Package, _ iverson.Finding) string {
+			switch {
+			case pkg.Module == nil:
+				return "none"
+			case pkg.Module.Version == "":
+				return pkg.Module.Path
+			}
+			return pkg.Module.Path + "@" + pkg.Module.Version
+		}, nil
+	case "values":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			if f.Values == "" {
+				return "other"
+			}
+			// constants other than 0 and 1 are lumped together, so the keys are few
+			vs := strings.Split(f.Values, ",")
+			for i, v := range vs {
+				if v != "0" && v != "1" && v != "x" {
+					vs[i] = "c"
+				}
+			}
+			return strings.Join(vs, ",")
+		}, nil
+	case "composed":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			if f.Composed == 0 {
+				return "other"
+			}
+			return strconv.Itoa(f.Composed)
+		}, nil
+	case "cond":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Cond, "other")
+		}, nil
+	case "where":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.Where, "other")
+		}, nil
+	case "api":
+		return func(_ *packages.Package, f iverson.Finding) string {
+			return cmp.Or(f.API, "other")
+		}, nil
+	case "func":
+		return func(pkg *packages.Package, f iverson.Finding) string {
+			if f.Func == "" {
+				return pkg.PkgPath + " (package level)"
+			}
+			return pkg.PkgPath + "." + f.Func
+		}, nil
+	case "file":
+		return func(pkg *packages.Package, f iverson.Finding) string {
+			return pkg.PkgPath + "/" + filepath.Base(f.Pos.Filename)
+		}, nil
 	}
+	return nil, fmt.Errorf("unknown -by key %q", by)
 }
 
-func Find(pkg *packages.Package) (implicit, explicit int) {
-	c := newCounter(pkg)
+// pkgSize is the number of bytes of source in pkg.
+func pkgSize(pkg *packages.Package) int {
+	n := 0
 	for _, file := range pkg.Syntax {
-		ast.Inspect(file, c.inspect)
+		n += pkg.Fset.File(file.FileStart).Size()
 	}
-	return c.implicit, c.explicit
+	return n
 }
 
-func PotentialIversonIf(pkg *packages.Package, cond *ast.IfStmt) bool {
-	if cond.Else == nil {
-		return false
+// label identifies pkg in summaries by import path and name,
+// noting if it is a test variant and the platform it was loaded for with -platforms.
+func label(pkg *packages.Package) string {
+	notes := []string{pkg.Name}
+	if iverson.IsTest(pkg) {
+		notes = append(notes, "test")
 	}
-	elseBlock, ok := cond.Else.(*ast.BlockStmt)
-	if !ok {
-		return false
+	if _, platform := iverson.SplitPlatform(pkg.ID); platform != "" {
+		notes = append(notes, platform)
 	}
-	return BranchOnlySetsNumber(pkg, cond.Body) && BranchOnlySetsNumber(pkg, elseBlock)
+	return fmt.Sprintf("%s (%s)", pkg.PkgPath, strings.Join(notes, ", "))
 }
 
-// BranchOnlySetsNumber true for an if without an else whose body is just x = n for a ~number which is either a literal or ident
-func BranchOnlySetsNumber(pkg *packages.Package, body *ast.BlockStmt) bool {
-	if len(body.List) != 1 {
-		return false
+// parsePlatforms parses the -platforms list, returning nil if it is empty.
+func parsePlatforms(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
 	}
-	stmt := body.List[0]
-	assign, ok := stmt.(*ast.AssignStmt)
-	if !ok {
-		return false
+	var plats []string
+	for p := range strings.SplitSeq(list, ",") {
+		goos, goarch, ok := strings.Cut(p, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return nil, fmt.Errorf("bad -platforms entry %q: want GOOS/GOARCH, like linux/amd64", p)
+		}
+		if slices.Contains(plats, p) {
+			return nil, fmt.Errorf("-platforms lists %s twice", p)
+		}
+		plats = append(plats, p)
 	}
-	if assign.Tok != token.ASSIGN {
-		return false
+	return plats, nil
+}
+
+// inTestFile reports whether f is in a _test.go file.
+func inTestFile(f iverson.Finding) bool {
+	return strings.HasSuffix(f.Pos.Filename, "_test.go")
+}
+
+// summary formats the implicit and explicit counts
+// followed by any other kinds in parentheses, but for definitions, which are set against the call sites of -definitions.
+func summary(counts map[string]int) string {
+	return summaryTests(counts, nil)
+}
+
+// summaryTests is summary with how many of the implicit and explicit findings,
+// and all of them, are in _test.go files, by the counts tests, where any are.
+func summaryTests(counts, tests map[string]int) string {
+	implicit, explicit := counts[iverson.Implicit], counts[iverson.Explicit]
+	inTests := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (%d in tests)", n)
 	}
-	if len(assign.Lhs) != 1 {
-		return false
+	s := fmt.Sprintf("%d implicit%s, %d explicit%s; all %d%s",
+		implicit, inTests(tests[iverson.Implicit]), explicit, inTests(tests[iverson.Explicit]),
+		implicit+explicit, inTests(tests[iverson.Implicit]+tests[iverson.Explicit]))
+	var other []string
+	for _, kind := range []string{iverson.Degenerate, iverson.RoundTrip, iverson.IntAsBool, iverson.Unverified, iverson.Increment, iverson.Probable} {
+		if n := counts[kind]; n > 0 {
+			other = append(other, fmt.Sprintf("%d %s", n, kind))
+		}
 	}
-	x := assign.Rhs[0]
-	switch x.(type) {
-	case *ast.BasicLit, *ast.Ident:
-	default:
-		return false
+	if len(other) > 0 {
+		s += " (" + strings.Join(other, ", ") + ")"
 	}
-	return numeric(pkg.TypesInfo.TypeOf(x))
+	return s
 }
 
-// IsBracketFunc returns true if the typ is a func from a ~bool to a ~number.
-func IsBracketFunc(typ types.Type) bool {
-	sig, ok := typ.Underlying().(*types.Signature)
-	if !ok {
-		return false
-	}
-	if sig.Recv() != nil {
-		return false
-	}
-	in, out := sig.Params(), sig.Results()
-	if in == nil || out == nil {
-		return false
+// pkgRegexp is the -pkg-filter flag.
+type pkgRegexp struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (p *pkgRegexp) String() string {
+	if p.re == nil {
+		return ""
 	}
-	if in.Len() != 1 || out.Len() != 1 || sig.Variadic() {
-		return false
+	if p.negate {
+		return "!" + p.re.String()
 	}
-	return boolish(in.At(0).Type()) && numeric(out.At(0).Type())
+	return p.re.String()
 }
 
-func IsMapBracket(typ types.Type) bool {
-	m, ok := typ.(*types.Map)
-	if !ok {
-		return false
+func (p *pkgRegexp) Set(v string) error {
+	expr, negate := strings.CutPrefix(v, "!")
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
 	}
-	return boolish(m.Key()) && numeric(m.Elem())
+	p.re, p.negate = re, negate
+	return nil
 }
 
-func boolish(typ types.Type) bool {
-	if typ == nil {
-		return false
-	}
-	t, ok := types.Default(typ).Underlying().(*types.Basic)
-	if !ok {
-		return false
-	}
-	return t.Kind() == types.Bool
+// match reports whether the package with import path pkgPath should be analyzed.
+func (p *pkgRegexp) match(pkgPath string) bool {
+	return p.re == nil || p.re.MatchString(pkgPath) != p.negate
 }
 
-func numeric(typ types.Type) bool {
-	if typ == nil {
-		return false
+// funcNames is the -helpers or -not-helpers flag, the set of full names of funcs and methods.
+type funcNames map[string]bool
+
+func (f funcNames) String() string {
+	return strings.Join(slices.Sorted(maps.Keys(f)), ",")
+}
+
+// Set adds the names in the comma separated list v to those of any earlier use of the flag.
+func (f funcNames) Set(v string) error {
+	for name := range strings.SplitSeq(v, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !strings.Contains(name, ".") {
+			return fmt.Errorf("%q is not the full name of a func or method, like example.com/m.btoi or (*example.com/m.T).Btoi", name)
+		}
+		f[name] = true
 	}
-	t, ok := types.Default(typ).Underlying().(*types.Basic)
-	if !ok {
-		return false
+	return nil
+}
+
+// pkgPatterns is the -exclude flag.
+type pkgPatterns struct {
+	patterns []string
+	res      []*regexp.Regexp
+}
+
+func (p *pkgPatterns) String() string {
+	return strings.Join(p.patterns, ",")
+}
+
+// Set adds the patterns in the comma separated list v to those of any earlier -exclude.
+func (p *pkgPatterns) Set(v string) error {
+	for pattern := range strings.SplitSeq(v, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`)
+		// as with go list, x/... matches x too
+		if rest, ok := strings.CutSuffix(expr, `/.*`); ok {
+			expr = rest + `(/.*)?`
+		}
+		p.patterns = append(p.patterns, pattern)
+		p.res = append(p.res, regexp.MustCompile("^"+expr+"$"))
 	}
-	switch t.Kind() {
-	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64, types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Float32, types.Float64, types.Complex64, types.Complex128:
-		return true
+	return nil
+}
+
+// match reports whether the package with import path pkgPath matches any of the patterns.
+func (p *pkgPatterns) match(pkgPath string) bool {
+	for _, re := range p.res {
+		if re.MatchString(pkgPath) {
+			return true
+		}
 	}
 	return false
-
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// groupKeys are the -by keys that -matrix tabulates, each classifying every finding.
+// The file and func keys are left out, as they have a value for every file or function with a finding.
+var groupKeys = []string{"alloc", "api", "build", "composed", "cond", "go", "lines", "module", "owner", "reach", "rewrite", "ssa", "test", "type", "usage", "values", "where"}
+
+// kinds are all kinds of finding in the order they are reported.
+var kinds = []string{iverson.Implicit, iverson.Explicit, iverson.Degenerate, iverson.RoundTrip, iverson.IntAsBool, iverson.Unverified, iverson.Increment, iverson.Probable, iverson.Definition}
+
+// A matrix counts findings by kind for each value of each -by key.
+type matrix map[string]map[string]map[string]int // key → value → kind → count
+
+func (m matrix) add(pkg *packages.Package, f iverson.Finding) {
+	for _, key := range groupKeys {
+		groupBy, _ := grouping(key)
+		value := groupBy(pkg, f)
+		if m[key] == nil {
+			m[key] = map[string]map[string]int{}
+		}
+		if m[key][value] == nil {
+			m[key][value] = map[string]int{}
+		}
+		m[key][value][f.Kind]++
+	}
+}
+
+// columns returns the kinds found at all.
+// Every finding is counted once for each key, so checking any one key suffices.
+func (m matrix) columns() []string {
+	var cols []string
+	for _, kind := range kinds {
+		for _, counts := range m[groupKeys[0]] {
+			if counts[kind] > 0 {
+				cols = append(cols, kind)
+				break
+			}
+		}
+	}
+	return cols
+}
+
+// print writes m as a table with a row for each key=value and a column for each kind found.
+func (m matrix) print(w io.Writer) error {
+	cols := m.columns()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "\t%s\n", strings.Join(cols, "\t"))
+	for _, key := range groupKeys {
+		for _, value := range slices.Sorted(maps.Keys(m[key])) {
+			fmt.Fprintf(tw, "%s=%s", key, value)
+			for _, kind := range cols {
+				fmt.Fprintf(tw, "\t%d", m[key][value][kind])
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+	return tw.Flush()
+}
+
+// log writes a record for each row of m.
+func (m matrix) log() {
+	for _, key := range groupKeys {
+		for _, value := range slices.Sorted(maps.Keys(m[key])) {
+			attrs := []any{"key", key, "value", value}
+			for _, kind := range kinds {
+				attrs = append(attrs, kind, m[key][value][kind])
+			}
+			slog.Info("matrix", attrs...)
+		}
+	}
+}
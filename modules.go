@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// moduleIndex finds the module containing a package
+// from the modules of the loaded packages.
+type moduleIndex struct {
+	byPkg   map[string]string // import path to module path
+	modules []string
+}
+
+func newModuleIndex(ps []*packages.Package) *moduleIndex {
+	idx := &moduleIndex{byPkg: map[string]string{}}
+	seen := map[string]bool{}
+	packages.Visit(ps, nil, func(pkg *packages.Package) {
+		if pkg.Module == nil {
+			return
+		}
+		idx.byPkg[pkg.PkgPath] = pkg.Module.Path
+		if !seen[pkg.Module.Path] {
+			seen[pkg.Module.Path] = true
+			idx.modules = append(idx.modules, pkg.Module.Path)
+		}
+	})
+	return idx
+}
+
+// lookup returns the module of the package with the given import path,
+// falling back to the longest known module path that is a prefix of it,
+// or "" if the module is unknown.
+func (idx *moduleIndex) lookup(pkgPath string) string {
+	if mod, ok := idx.byPkg[pkgPath]; ok {
+		return mod
+	}
+	best := ""
+	for _, mod := range idx.modules {
+		if (pkgPath == mod || strings.HasPrefix(pkgPath, mod+"/")) && len(mod) > len(best) {
+			best = mod
+		}
+	}
+	return best
+}
+
+// attribute fills in the Module of findings whose callee was defined in another package.
+func (idx *moduleIndex) attribute(found []iverson.Finding) {
+	for i, f := range found {
+		if f.CalleePkg != "" && f.Module == "" {
+			found[i].Module = idx.lookup(f.CalleePkg)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log/slog"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// reachable returns the IDs of the packages imported, directly or not, by the main packages among roots,
+// including the mains themselves, or nil if there are no mains.
+func reachable(roots []*packages.Package) map[string]bool {
+	var reach map[string]bool
+	for _, pkg := range roots {
+		if pkg.Name != "main" {
+			continue
+		}
+		if reach == nil {
+			reach = map[string]bool{}
+		}
+		n := 0
+		packages.Visit([]*packages.Package{pkg}, func(p *packages.Package) bool {
+			n++
+			reach[p.ID] = true
+			return true
+		}, nil)
+		slog.Debug("reachable packages", "main", pkg.ID, "packages", n)
+	}
+	return reach
+}
+
+// all returns roots and every package they import, directly or not, each once.
+func all(roots []*packages.Package) []*packages.Package {
+	var ps []*packages.Package
+	packages.Visit(roots, func(p *packages.Package) bool {
+		ps = append(ps, p)
+		return true
+	}, nil)
+	return ps
+}
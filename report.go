@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// A Reporter writes the findings of each package as they are reported.
+type Reporter interface {
+	Report(pkg *packages.Package, found []iverson.Finding) error
+}
+
+// logReporter logs a record for each finding, as text or with -log-json as JSON.
+type logReporter struct{}
+
+func (logReporter) Report(pkg *packages.Package, found []iverson.Finding) error {
+	logFindings(pkg, found)
+	return nil
+}
+
+// jsonReporter writes a -format=json record for each finding.
+type jsonReporter struct{ enc *json.Encoder }
+
+func (r jsonReporter) Report(pkg *packages.Package, found []iverson.Finding) error {
+	for _, f := range found {
+		if err := r.enc.Encode(newJSONFinding(pkg, f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvReporter writes a -format=csv row for each finding.
+type csvReporter struct{ w *csv.Writer }
+
+func (r csvReporter) Report(pkg *packages.Package, found []iverson.Finding) error {
+	for _, f := range found {
+		if err := r.w.Write(csvRow(pkg, f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sarifReporter collects a SARIF result for each finding, to write all at once.
+type sarifReporter struct{ results []sarifResult }
+
+func (r *sarifReporter) Report(pkg *packages.Package, found []iverson.Finding) error {
+	for _, f := range found {
+		r.results = append(r.results, newSARIFResult(pkg, f))
+	}
+	return nil
+}
+
+// A reporter collects the findings of each package and writes them as the -format,
+// with the summaries, breakdowns, and side files that the other flags ask for.
+type reporter struct {
+	format  string
+	groupBy func(*packages.Package, iverson.Finding) string
+	// reporters are the -findings stream, if any, and the -format output, if it has a record per finding
+	reporters []Reporter
+	// multi is whether there is more than one package, so the text summary has a total
+	multi bool
+	// coverage is the number of packages analyzed within the -budget, if any
+	coverage *jsonCoverage
+
+	total     map[string]int
+	tests     map[string]int // counts of the findings in _test.go files, also in total
+	groups    map[string]map[string]int
+	cross     matrix
+	html      htmlReport
+	failing   int
+	inLoops   int               // conversions that may allocate a literal each time around a loop
+	calls     int               // call sites of bracket funcs, for the helpers summary of -definitions
+	removed   map[string]int    // counts of the findings in the -baseline gone this run, if any
+	generated map[string]int    // counts of the findings in generated files, left out without -include-generated
+	audit     *fixAudit         // what -fix would fix, with -fix-audit
+	repos     repoRollups       // counts by the repository of each module, with corpus
+	dups      map[string]string // files left out by -dedupe-identical-files, to the files they duplicate
+	duplicate map[string]int    // counts of the findings in them
+	out       []string          // text summary of each package, in the order they were loaded, unless printed already with -stream
+	summaries []jsonPackage
+	stdout    io.Writer // where the results go, stdout unless -diff takes it
+	enc       *json.Encoder
+	sarif     *sarifReporter
+	rows      *csv.Writer
+}
+
+// newReporter returns a reporter writing the results of n packages to out as format.
+func newReporter(out io.Writer, format string, groupBy func(*packages.Package, iverson.Finding) string, n int) *reporter {
+	r := &reporter{
+		format:    format,
+		groupBy:   groupBy,
+		multi:     n > 1,
+		total:     map[string]int{},
+		tests:     map[string]int{},
+		groups:    map[string]map[string]int{},
+		cross:     matrix{},
+		generated: map[string]int{},
+		duplicate: map[string]int{},
+		out:       make([]string, n),
+		summaries: make([]jsonPackage, n),
+		stdout:    out,
+		enc:       json.NewEncoder(out),
+		rows:      csv.NewWriter(out),
+	}
+	switch *findings {
+	case "":
+		if *verbose {
+			r.reporters = append(r.reporters, logReporter{})
+		}
+	case "text":
+		r.reporters = append(r.reporters, logReporter{})
+	case "json":
+		r.reporters = append(r.reporters, jsonReporter{json.NewEncoder(os.Stderr)})
+	}
+	switch format {
+	case "json":
+		r.reporters = append(r.reporters, jsonReporter{r.enc})
+	case "sarif":
+		r.sarif = &sarifReporter{}
+		r.reporters = append(r.reporters, r.sarif)
+	case "csv":
+		r.rows.Write(csvHeader)
+		r.reporters = append(r.reporters, csvReporter{r.rows})
+	}
+	if corpusModules != nil {
+		r.repos = repoRollups{}
+	}
+	status.Total = r.total
+	return r
+}
+
+// add reports the findings in pkg, the ith package loaded, and returns their counts by kind.
+func (r *reporter) add(i int, pkg *packages.Package, found []iverson.Finding) (map[string]int, error) {
+	for _, rr := range r.reporters {
+		if err := rr.Report(pkg, found); err != nil {
+			return nil, err
+		}
+	}
+	counts := map[string]int{}
+	var tests map[string]int
+	calls := 0
+	for _, f := range found {
+		sev := severity.of(f)
+		counts[f.Kind]++
+		if inTestFile(f) {
+			if tests == nil {
+				tests = map[string]int{}
+			}
+			tests[f.Kind]++
+		}
+		if r.groupBy != nil {
+			key := r.groupBy(pkg, f)
+			if r.groups[key] == nil {
+				r.groups[key] = map[string]int{}
+			}
+			r.groups[key][f.Kind]++
+		}
+		if *matrixOut {
+			r.cross.add(pkg, f)
+		}
+		if failLevel.set && sev >= failLevel.sev {
+			r.failing++
+		}
+		if f.Alloc == "loop" {
+			r.inLoops++
+		}
+		if isCallSite(f) {
+			calls++
+		}
+	}
+	if *htmlOut != "" {
+		r.html.add(pkg, found, counts)
+	}
+	if r.repos != nil {
+		r.repos.add(pkg, counts, r.dups)
+	}
+	if len(counts) > 0 {
+		for kind, n := range counts {
+			r.total[kind] += n
+		}
+		for kind, n := range tests {
+			r.tests[kind] += n
+		}
+		r.out[i] = fmt.Sprintf("%s: %s", label(pkg), summaryTests(counts, tests))
+		r.summaries[i] = newJSONPackage(pkg, counts)
+		r.summaries[i].Tests = tests
+		if *defs {
+			r.out[i] += helpers(counts[iverson.Definition], calls)
+			r.summaries[i].CallSites = calls
+			r.calls += calls
+		}
+	}
+	if *stream {
+		if err := r.flush(i); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// flush writes the results of the ith package so far for -stream:
+// its text summary, which is then not written again, its package record, or its rows.
+func (r *reporter) flush(i int) error {
+	switch r.format {
+	case "json":
+		if r.summaries[i].ID != "" {
+			return r.enc.Encode(jsonPackageRecord{"package", r.summaries[i]})
+		}
+	case "csv":
+		r.rows.Flush()
+		return r.rows.Error()
+	case "text":
+		if r.out[i] != "" {
+			fmt.Fprintln(r.stdout, r.out[i])
+			r.out[i] = ""
+		}
+	}
+	return nil
+}
+
+// isCallSite reports whether f is a call of a bracket func or func literal, counted against the definitions of them by -definitions.
+func isCallSite(f iverson.Finding) bool {
+	return (f.Form == "call" || f.Form == "closure") && (f.Kind == iverson.Explicit || f.Kind == iverson.Degenerate)
+}
+
+// helpers summarizes the bracket funcs defined and the call sites of them for -definitions.
+func helpers(defined, calls int) string {
+	return fmt.Sprintf("; helpers defined: %d, call sites: %d", defined, calls)
+}
+
+// write writes the side files and then the results of all packages to stdout,
+// and returns an error if any finding is at or above the -fail-on severity.
+func (r *reporter) write() error {
+	if *matrixOut && len(r.cross) > 0 {
+		r.cross.log()
+	}
+	if *totals != "" {
+		if err := writeCSVTotals(*totals, r.summaries, r.total); err != nil {
+			return err
+		}
+	}
+	if *htmlOut != "" {
+		if err := r.html.write(*htmlOut, r.total); err != nil {
+			return err
+		}
+	}
+
+	switch r.format {
+	case "csv":
+		r.rows.Flush()
+		if err := r.rows.Error(); err != nil {
+			return err
+		}
+	case "sarif":
+		if err := writeSARIF(r.stdout, r.sarif.results); err != nil {
+			return err
+		}
+	case "json":
+		sum := jsonSummary{Record: "summary", Packages: []jsonPackage{}, Total: r.total, Tests: r.tests, By: r.groups, InLoops: r.inLoops, Coverage: r.coverage, Removed: r.removed, Generated: r.generated, FixAudit: r.audit}
+		if *defs {
+			sum.CallSites = r.calls
+		}
+		for _, p := range r.summaries {
+			if p.ID != "" {
+				sum.Packages = append(sum.Packages, p)
+			}
+		}
+		if *matrixOut {
+			sum.Matrix = r.cross
+		}
+		sum.Repos = r.repos.summaries()
+		sum.Failed = status.Failed
+		if len(r.dups) > 0 {
+			sum.Duplicates = &jsonDuplicates{Files: len(r.dups), Counts: r.duplicate}
+		}
+		if err := r.enc.Encode(sum); err != nil {
+			return err
+		}
+	default:
+		if *viz != "" {
+			if err := writeViz(r.stdout, *viz, r.summaries); err != nil {
+				return err
+			}
+			break
+		}
+		for _, line := range r.out {
+			if line != "" {
+				fmt.Fprintln(r.stdout, line)
+			}
+		}
+		if r.multi {
+			total := summaryTests(r.total, r.tests)
+			if *defs {
+				total += helpers(r.total[iverson.Definition], r.calls)
+			}
+			fmt.Fprintf(r.stdout, "\nTOTAL: %s\n", total)
+		}
+		if c := r.coverage; c != nil {
+			pct := 100.0
+			if c.Packages > 0 {
+				pct = 100 * float64(c.Covered) / float64(c.Packages)
+			}
+			fmt.Fprintf(r.stdout, "\nCOVERAGE: %d of %d packages (%.0f%%)\n", c.Covered, c.Packages, pct)
+		}
+		if r.inLoops > 0 {
+			fmt.Fprintf(r.stdout, "\nALLOCATING IN LOOPS: %d\n", r.inLoops)
+		}
+		if len(r.generated) > 0 {
+			fmt.Fprintf(r.stdout, "\nGENERATED, NOT COUNTED: %s\n", summary(r.generated))
+		}
+		if len(status.Failed) > 0 {
+			fmt.Fprintf(r.stdout, "\nFAILED TO LOAD: %d\n", len(status.Failed))
+			for _, target := range slices.Sorted(maps.Keys(status.Failed)) {
+				fmt.Fprintln(r.stdout, target)
+			}
+		}
+		if len(r.dups) > 0 {
+			fmt.Fprintf(r.stdout, "\nDUPLICATE FILES, NOT COUNTED: %d; %s\n", len(r.dups), summary(r.duplicate))
+		}
+		if r.removed != nil {
+			fmt.Fprintf(r.stdout, "\nREMOVED: %s\n", summary(r.removed))
+		}
+		if a := r.audit; a != nil {
+			unfixed := 0
+			for _, n := range a.Unfixed {
+				unfixed += n
+			}
+			fmt.Fprintf(r.stdout, "\nFIX AUDIT: %d fixable, %d need attention\n", a.Fixable, unfixed)
+			for _, reason := range slices.Sorted(maps.Keys(a.Unfixed)) {
+				fmt.Fprintf(r.stdout, "%s: %d\n", reason, a.Unfixed[reason])
+			}
+		}
+		if r.groupBy != nil && len(r.groups) > 0 {
+			fmt.Fprintf(r.stdout, "\nBY %s:\n", strings.ToUpper(*by))
+			for _, key := range slices.Sorted(maps.Keys(r.groups)) {
+				fmt.Fprintf(r.stdout, "%s: %s\n", key, summary(r.groups[key]))
+			}
+		}
+		if len(r.repos) > 0 {
+			fmt.Fprintf(r.stdout, "\nBY REPOSITORY:\n")
+			for _, repo := range r.repos.summaries() {
+				fmt.Fprintf(r.stdout, "%s: %s; %.1f per 1000 lines", repo.Repo, summary(repo.Counts), repo.Density)
+				for i, top := range repo.Top {
+					sep := ", "
+					if i == 0 {
+						sep = "; top "
+					}
+					fmt.Fprintf(r.stdout, "%s%s %d", sep, top.Path, top.All)
+				}
+				fmt.Fprintln(r.stdout)
+			}
+		}
+		if *matrixOut && len(r.cross) > 0 {
+			fmt.Fprintf(r.stdout, "\nMATRIX:\n")
+			if err := r.cross.print(r.stdout); err != nil {
+				return err
+			}
+		}
+	}
+	status.Failing = r.failing
+	if r.failing > 0 {
+		return fmt.Errorf("%d findings with severity %s or higher", r.failing, failLevel.sev)
+	}
+	return nil
+}
+
+// Report regenerates the results of an earlier run from the records it wrote with -format=json,
+// named by the -from flag in args, without loading or analyzing any packages.
+// The flags for the output apply as they would to a new run,
+// as do -pkg-filter, -exclude, -no-serialization, and -no-test-files, but those that change what is found do not.
+func Report(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	from := fs.String("from", "", "read the records written by -format=json from this `file`, or - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || fs.NArg() > 0 {
+		return errors.New("usage: report -from file")
+	}
+	groupBy, err := grouping(*by)
+	if err != nil {
+		return err
+	}
+	outFormat, err := outputFormat()
+	if err != nil {
+		return err
+	}
+	r := io.Reader(os.Stdin)
+	if *from != "-" {
+		f, err := os.Open(*from)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	ps, found, coverage, err := readFindings(r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *from, err)
+	}
+
+	rep := newReporter(os.Stdout, outFormat, groupBy, len(ps))
+	rep.coverage = coverage
+	for i, pkg := range ps {
+		if !pkgFilter.match(pkg.PkgPath) || excludes.match(pkg.PkgPath) {
+			slog.Debug("skipping package", "pkg", pkg.ID, "reason", "filtered")
+			continue
+		}
+		if *noSerial {
+			found[i] = slices.DeleteFunc(found[i], func(f iverson.Finding) bool {
+				return f.Usage == "serialization"
+			})
+		}
+		if *noTests {
+			found[i] = slices.DeleteFunc(found[i], inTestFile)
+		}
+		if _, err := rep.add(i, pkg, found[i]); err != nil {
+			return err
+		}
+	}
+	return rep.write()
+}
+
+// readFindings reads the records written by -format=json from r
+// and returns the packages with findings in the order they were written, their findings,
+// and the coverage of the -budget, if any.
+// Only the IDs, import paths, names, and modules of the packages are known.
+func readFindings(r io.Reader) ([]*packages.Package, [][]iverson.Finding, *jsonCoverage, error) {
+	var ps []*packages.Package
+	var found [][]iverson.Finding
+	index := map[string]int{}
+	var sum *jsonSummary
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, nil, err
+		}
+		var rec struct {
+			Record string `json:"record"`
+		}
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return nil, nil, nil, err
+		}
+		switch rec.Record {
+		case "finding":
+			var f jsonFinding
+			if err := json.Unmarshal(raw, &f); err != nil {
+				return nil, nil, nil, err
+			}
+			i, ok := index[f.Package]
+			if !ok {
+				i = len(ps)
+				index[f.Package] = i
+				ps = append(ps, &packages.Package{ID: f.Package})
+				found = append(found, nil)
+			}
+			found[i] = append(found[i], f.finding())
+		case "package":
+			// the summary has the packages too
+		case "summary":
+			sum = new(jsonSummary)
+			if err := json.Unmarshal(raw, sum); err != nil {
+				return nil, nil, nil, err
+			}
+		default:
+			return nil, nil, nil, fmt.Errorf("unknown record %q", rec.Record)
+		}
+	}
+	if sum == nil {
+		return nil, nil, nil, errors.New("no summary record")
+	}
+	for _, p := range sum.Packages {
+		if i, ok := index[p.ID]; ok {
+			ps[i].PkgPath, ps[i].Name = p.Path, p.Name
+			if p.Module != "" {
+				mod, version, _ := strings.Cut(p.Module, "@")
+				ps[i].Module = &packages.Module{Path: mod, Version: version}
+			}
+		}
+	}
+	for _, pkg := range ps {
+		if pkg.PkgPath == "" {
+			pkg.PkgPath, _, _ = strings.Cut(pkg.ID, " [")
+			pkg.Name = path.Base(pkg.PkgPath)
+		}
+	}
+	return ps, found, sum.Coverage, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+func resourceUsage() (cpu, childCPU time.Duration, maxRSS int64, ok bool) {
+	return 0, 0, 0, false
+}
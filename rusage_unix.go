@@ -0,0 +1,28 @@
+//go:build unix
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// resourceUsage returns the CPU time used by this process and by its waited for children,
+// such as the go command run by go/packages, and the peak resident set size in bytes.
+func resourceUsage() (cpu, childCPU time.Duration, maxRSS int64, ok bool) {
+	var self, children syscall.Rusage
+	if syscall.Getrusage(syscall.RUSAGE_SELF, &self) != nil || syscall.Getrusage(syscall.RUSAGE_CHILDREN, &children) != nil {
+		return 0, 0, 0, false
+	}
+	maxRSS = int64(self.Maxrss)
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		// everyone else reports kilobytes
+		maxRSS *= 1024
+	}
+	return cpuTime(self), cpuTime(children), maxRSS, true
+}
+
+func cpuTime(r syscall.Rusage) time.Duration {
+	return time.Duration(r.Utime.Nano() + r.Stime.Nano())
+}
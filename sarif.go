@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// sarifRules describes the SARIF rule for each kind of finding.
+var sarifRules = []struct {
+	id, kind, form, text string
+}{
+	{"implicit-iverson", iverson.Implicit, "if", "if-else setting a number to 0 or 1 by a bool"},
+	{"implicit-iverson-init", iverson.Implicit, "init", "if setting a number to 0 or 1 by a bool just after initializing it"},
+	{"implicit-iverson-switch", iverson.Implicit, "switch", "switch setting a number to 0 or 1 by a bool"},
+	{"implicit-iverson-return", iverson.Implicit, "return", "if returning 0 or 1 by a bool"},
+	{"explicit-bracket-call", iverson.Explicit, "call", "call of a func from bool to number"},
+	{"closure-bracket-call", iverson.Explicit, "closure", "call of a func literal from bool to number"},
+	{"map-bracket", iverson.Explicit, "index", "read of a map from bool to number"},
+	{"map-literal-bracket", iverson.Explicit, "literal", "read of a map literal from bool to number"},
+	{"ternary-helper-call", iverson.Explicit, "ternary", "call of a func choosing between two numbers by a bool"},
+	{"unsafe-bracket", iverson.Explicit, "unsafe", "reinterpretation of a bool as a number with unsafe.Pointer"},
+	{"degenerate-bracket-call", iverson.Degenerate, "", "bracket conversion of a constant or compared against a constant"},
+	{"round-trip", iverson.RoundTrip, "", "converted bool compared against 0 or 1"},
+	{"int-as-bool", iverson.IntAsBool, "", "integer field only ever set to 0 or 1"},
+	{"unverified-iverson", iverson.Unverified, "", "if-else setting a variable of unknown type to 0 or 1"},
+	{"conditional-compound-assignment", iverson.Increment, "compound", "if adding a number to or subtracting it from another by a bool"},
+	{"conditional-increment", iverson.Increment, "", "if incrementing or decrementing a number by a bool"},
+	{"probable-helper", iverson.Probable, "", "func named like a bool to number helper"},
+	{"bracket-closure-definition", iverson.Definition, "closure", "func literal from bool to number"},
+	{"bracket-func-definition", iverson.Definition, "", "declaration of a func from bool to number"},
+}
+
+// sarifRule returns the rule id for f.
+func sarifRule(f iverson.Finding) string {
+	for _, r := range sarifRules {
+		if r.kind == f.Kind && (r.form == "" || r.form == f.Form) {
+			return r.id
+		}
+	}
+	return f.Kind
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string              `json:"name"`
+		InformationURI string              `json:"informationUri"`
+		Rules          []sarifRuleDescribe `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRuleDescribe struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// sarifLevels maps each severity to a SARIF result level.
+var sarifLevels = map[Severity]string{Info: "note", Warning: "warning", Error: "error"}
+
+func newSARIFResult(pkg *packages.Package, f iverson.Finding) sarifResult {
+	var loc sarifLocation
+	loc.PhysicalLocation.ArtifactLocation.URI = artifactURI(f.Pos.Filename)
+	loc.PhysicalLocation.Region.StartLine = f.Pos.Line
+	loc.PhysicalLocation.Region.StartColumn = f.Pos.Column
+	msg := f.Kind + " bool to number conversion in " + pkg.PkgPath
+	if f.Func != "" {
+		msg += "." + f.Func
+	}
+	if f.Proposed != "" {
+		msg += ", proposed as " + f.Proposed
+	}
+	return sarifResult{
+		RuleID:              sarifRule(f),
+		Level:               sarifLevels[severity.of(f)],
+		Message:             sarifMessage{msg},
+		Locations:           []sarifLocation{loc},
+		PartialFingerprints: map[string]string{"id/v1": f.ID},
+	}
+}
+
+// artifactURI returns the path of file relative to the working directory if it is under it,
+// as code scanning expects, or else the absolute path, slash separated.
+func artifactURI(file string) string {
+	if wd, err := filepath.Abs("."); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// writeSARIF writes results as a SARIF 2.1.0 log with one run.
+func writeSARIF(w io.Writer, results []sarifResult) error {
+	run := sarifRun{Results: results}
+	if run.Results == nil {
+		run.Results = []sarifResult{}
+	}
+	run.Tool.Driver.Name = "issue61915"
+	run.Tool.Driver.InformationURI = "https://github.com/jimmyfrasche/issue61915"
+	for _, r := range sarifRules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRuleDescribe{r.id, sarifMessage{r.text}})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
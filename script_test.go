@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rogpeppe/go-internal/testscript"
+)
+
+func TestMain(m *testing.M) {
+	testscript.Main(m, map[string]func(){
+		"issue61915": main,
+	})
+}
+
+// TestScript runs the end to end tests in testdata/script.
+func TestScript(t *testing.T) {
+	gocache, err := exec.Command("go", "env", "GOCACHE").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testscript.Run(t, testscript.Params{
+		Dir: "testdata/script",
+		Setup: func(env *testscript.Env) error {
+			// the scripts only use modules in their own $WORK
+			env.Setenv("GOCACHE", strings.TrimSpace(string(gocache)))
+			env.Setenv("GOPROXY", "off")
+			env.Setenv("GOFLAGS", "-mod=mod")
+			env.Setenv("GOTOOLCHAIN", "local")
+			return nil
+		},
+		Cmds: map[string]func(ts *testscript.TestScript, neg bool, args []string){
+			"zip":      zipCmd,
+			"lspframe": lspFrameCmd,
+		},
+	})
+}
+
+// zipCmd implements "zip file dir [prefix]", which archives dir,
+// keeping its name, or prefix if given, as the prefix of every file, as in module zips.
+func zipCmd(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 2 && len(args) != 3 {
+		ts.Fatalf("usage: zip file dir [prefix]")
+	}
+	out, err := os.Create(ts.MkAbs(args[0]))
+	ts.Check(err)
+	zw := zip.NewWriter(out)
+	dir := ts.MkAbs(args[1])
+	ts.Check(filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.Dir(dir), path)
+		if err != nil {
+			return err
+		}
+		if len(args) == 3 {
+			rel, err = filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.Join(args[2], rel)
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}))
+	ts.Check(zw.Close())
+	ts.Check(out.Close())
+}
+
+// lspFrameCmd implements "lspframe in out", which writes each line of in, a JSON-RPC message with $WORK expanded,
+// to out with the Content-Length header of the Language Server Protocol.
+func lspFrameCmd(ts *testscript.TestScript, neg bool, args []string) {
+	if neg || len(args) != 2 {
+		ts.Fatalf("usage: lspframe in out")
+	}
+	var out strings.Builder
+	for _, line := range strings.Split(ts.ReadFile(args[0]), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			line = strings.ReplaceAll(line, "$WORK", ts.Getenv("WORK"))
+			fmt.Fprintf(&out, "Content-Length: %d\r\n\r\n%s", len(line), line)
+		}
+	}
+	ts.Check(os.WriteFile(ts.MkAbs(args[1]), []byte(out.String()), 0o666))
+}
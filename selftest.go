@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// selftestModules lists module@version followed by the expected implicit and explicit counts.
+//
+//go:embed testdata/selftest.txt
+var selftestModules []byte
+
+// Selftest scans each module in the pinned list and reports an error
+// if any count differs from the recorded one.
+// If args is not empty, it names a file to use instead of the built-in list.
+func Selftest(ctx context.Context, args []string) error {
+	list := selftestModules
+	switch len(args) {
+	case 0:
+	case 1:
+		var err error
+		list, err = os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: selftest [file]")
+	}
+
+	expected, err := parseSelftest(list)
+	if err != nil {
+		return err
+	}
+
+	drift := 0
+	for _, want := range expected {
+		implicit, explicit, err := scanModule(ctx, want.module)
+		if err != nil {
+			return fmt.Errorf("%s: %w", want.module, err)
+		}
+		if implicit != want.implicit || explicit != want.explicit {
+			drift++
+			fmt.Printf("%s: got %d implicit, %d explicit; want %d implicit, %d explicit\n", want.module, implicit, explicit, want.implicit, want.explicit)
+		} else {
+			slog.Debug("selftest ok", "module", want.module, "implicit", implicit, "explicit", explicit)
+		}
+	}
+	if drift > 0 {
+		return fmt.Errorf("selftest: counts drifted for %d of %d modules", drift, len(expected))
+	}
+	return nil
+}
+
+type selftestEntry struct {
+	module             string
+	implicit, explicit int
+}
+
+func parseSelftest(list []byte) ([]selftestEntry, error) {
+	var entries []selftestEntry
+	sc := bufio.NewScanner(bytes.NewReader(list))
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || !strings.Contains(fields[0], "@") {
+			return nil, fmt.Errorf("selftest list:%d: want module@version implicit explicit", line)
+		}
+		implicit, err1 := strconv.Atoi(fields[1])
+		explicit, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("selftest list:%d: counts must be integers", line)
+		}
+		entries = append(entries, selftestEntry{fields[0], implicit, explicit})
+	}
+	return entries, sc.Err()
+}
+
+// scanModule counts the findings in all packages of module, given as path@version,
+// by requiring it from a throwaway module.
+func scanModule(ctx context.Context, module string) (implicit, explicit int, err error) {
+	dir, pattern, err := moduleWorkspace(ctx, module)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	ps, err := iverson.Packages(ctx, dir, pattern, iverson.LoadOptions{})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, pkg := range ps {
+		i, e := iverson.Count(iverson.Find(pkg, iverson.Options{}))
+		implicit += i
+		explicit += e
+	}
+	return implicit, explicit, nil
+}
+
+// moduleWorkspace creates a throwaway module requiring module, given as path@version or just path for the latest,
+// which the go command downloads through the module proxy,
+// and returns its directory, to remove when done, and the pattern matching every package in module.
+func moduleWorkspace(ctx context.Context, module string) (dir string, pattern []string, err error) {
+	dir, err = os.MkdirTemp("", "issue61915-")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := goCmd(ctx, dir, "mod", "init", "workspace"); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	if err := goCmd(ctx, dir, "get", module); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	path, _, _ := strings.Cut(module, "@")
+	return dir, []string{path + "/..."}, nil
+}
+
+func goCmd(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
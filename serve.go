@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// Serve runs a long-running server, which with -lsp is a language server speaking the Language Server Protocol
+// on stdin and stdout. It publishes the findings in each open Go file as diagnostics,
+// analyzing the packages in its directory, tests included, again whenever it is opened, changed, or saved,
+// with the unsaved contents of every open file.
+// The flags for the analysis apply as they would to any run, and the logs still go to stderr.
+func Serve(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	lsp := fs.Bool("lsp", false, "speak the Language Server Protocol on stdin and stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*lsp || fs.NArg() > 0 {
+		return errors.New("usage: serve -lsp")
+	}
+	return newLSPServer(os.Stdout).run(ctx, os.Stdin)
+}
+
+// lspSeverities are the DiagnosticSeverity of each Severity.
+var lspSeverities = map[Severity]int{Error: 1, Warning: 2, Info: 3}
+
+// JSON-RPC error codes.
+const (
+	lspParseError     = -32700
+	lspInvalidRequest = -32600
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+)
+
+// An lspError is the error of a JSON-RPC response.
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return e.Message
+}
+
+// An lspMessage is a request or notification from the client, which has no ID.
+type lspMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type lspTextDocument struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"` // in UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspPublishDiagnostics struct {
+	URI         string          `json:"uri"`
+	Version     *int            `json:"version,omitempty"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+// An openFile is a file open in the client.
+type openFile struct {
+	uri     string
+	version int
+	text    []byte
+}
+
+// An lspServer serves the findings in the files open in one client.
+// Each directory with an open file is analyzed in the background,
+// any analysis of it in flight being canceled by the next.
+type lspServer struct {
+	wg sync.WaitGroup // the analyses in flight
+
+	mu       sync.Mutex // guards out and the fields below
+	out      io.Writer
+	open     map[string]*openFile // by path
+	versions map[string]int       // of the analyses of each directory, so only the latest publishes
+	cancels  map[string]context.CancelFunc
+	shutdown bool
+}
+
+func newLSPServer(out io.Writer) *lspServer {
+	return &lspServer{
+		out:      out,
+		open:     map[string]*openFile{},
+		versions: map[string]int{},
+		cancels:  map[string]context.CancelFunc{},
+	}
+}
+
+// run handles the messages read from in until the exit notification,
+// returning an error if that was not after a shutdown request, or if in ends first.
+func (s *lspServer) run(ctx context.Context, in io.Reader) error {
+	r := bufio.NewReader(in)
+	defer func() {
+		s.mu.Lock()
+		for _, cancel := range s.cancels {
+			cancel()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+	}()
+	for {
+		data, err := readLSPMessage(r)
+		if err == io.EOF {
+			return errors.New("the client closed stdin without exit")
+		}
+		if err != nil {
+			return err
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.respond(json.RawMessage("null"), nil, &lspError{lspParseError, err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(ctx, msg)
+		if msg.ID == nil {
+			// no response to a notification, even to say what was wrong with it
+			if err != nil {
+				slog.Warn("bad notification", "method", msg.Method, "err", err)
+			}
+			continue
+		}
+		var rerr *lspError
+		if err != nil && !errors.As(err, &rerr) {
+			rerr = &lspError{lspInvalidParams, err.Error()}
+		}
+		s.respond(msg.ID, result, rerr)
+	}
+}
+
+// readLSPMessage reads the content of the next message from r after its headers,
+// returning io.EOF if r ends before it starts.
+func readLSPMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" && length < 0 {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading message headers: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if length < 0 {
+				// stray line breaks between messages
+				continue
+			}
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("bad Content-Length %q", strings.TrimSpace(value))
+			}
+		}
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading message: %w", err)
+	}
+	return data, nil
+}
+
+// handle handles msg, returning the result of a request.
+func (s *lspServer) handle(ctx context.Context, msg lspMessage) (json.RawMessage, error) {
+	if s.shutdown {
+		return nil, &lspError{lspInvalidRequest, "the server is shut down"}
+	}
+	var params struct {
+		TextDocument   lspTextDocument `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if len(msg.Params) > 0 && msg.Method != "initialize" {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+	}
+	doc := params.TextDocument
+	switch msg.Method {
+	case "initialize":
+		// textDocumentSync 1 sends the full contents of a file on each change
+		return json.RawMessage(`{"capabilities":{"textDocumentSync":{"openClose":true,"change":1,"save":{}}},"serverInfo":{"name":"issue61915"}}`), nil
+	case "initialized":
+	case "shutdown":
+		s.wg.Wait()
+		s.shutdown = true
+		return json.RawMessage("null"), nil
+	case "textDocument/didOpen":
+		return nil, s.update(ctx, doc.URI, func(path string) {
+			s.open[path] = &openFile{doc.URI, doc.Version, []byte(doc.Text)}
+		})
+	case "textDocument/didChange":
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(ctx, doc.URI, func(path string) {
+			if f := s.open[path]; f != nil {
+				f.version, f.text = doc.Version, []byte(text)
+			}
+		})
+	case "textDocument/didSave":
+		return nil, s.update(ctx, doc.URI, func(string) {})
+	case "textDocument/didClose":
+		path, err := uriPath(doc.URI)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.open[path]; ok {
+			delete(s.open, path)
+			s.publish(doc.URI, nil, nil)
+		}
+	default:
+		if msg.ID != nil {
+			return nil, &lspError{lspMethodNotFound, "method not found: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+// update applies the change to the open Go file at uri, with s.mu held,
+// and then analyzes its directory again.
+func (s *lspServer) update(ctx context.Context, uri string, change func(path string)) error {
+	path, err := uriPath(uri)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(path, ".go") {
+		return nil
+	}
+	s.mu.Lock()
+	change(path)
+	s.mu.Unlock()
+	s.analyze(ctx, filepath.Dir(path))
+	return nil
+}
+
+// analyze starts analyzing the packages in dir with the contents of the open files,
+// canceling any analysis of dir in flight, and publishes the findings in the open files of dir once done.
+// If the packages do not load, like while a file is half edited, the diagnostics already published stand.
+func (s *lspServer) analyze(ctx context.Context, dir string) {
+	s.mu.Lock()
+	if cancel := s.cancels[dir]; cancel != nil {
+		cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancels[dir] = cancel
+	s.versions[dir]++
+	version := s.versions[dir]
+	overlay := map[string][]byte{}
+	files := map[string]openFile{}
+	for path, f := range s.open {
+		overlay[path] = f.text
+		if filepath.Dir(path) == dir {
+			files[path] = *f
+		}
+	}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		found, err := findInDir(ctx, dir, overlay)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ctx.Err() != nil || s.versions[dir] != version {
+			return
+		}
+		delete(s.cancels, dir)
+		var lerr *iverson.LoadError
+		switch {
+		case errors.As(err, &lerr):
+			slog.Info("could not analyze", "dir", dir, "err", err, "errors", lerr.Errors)
+			return
+		case err != nil:
+			slog.Info("could not analyze", "dir", dir, "err", err)
+			return
+		}
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		slices.Sort(paths)
+		for _, path := range paths {
+			// a file closed or changed since has had or will have its own diagnostics
+			if f := s.open[path]; f != nil && f.version == files[path].version {
+				f := files[path]
+				s.publish(f.uri, &f, found[path])
+			}
+		}
+	}()
+}
+
+// findInDir finds the findings in the packages in dir, tests included, by file,
+// as in a run with the same flags.
+func findInDir(ctx context.Context, dir string, overlay map[string][]byte) (map[string][]iverson.Finding, error) {
+	ps, err := iverson.Packages(ctx, dir, []string{"."}, iverson.LoadOptions{Tests: true, Tags: *tags, Overlay: overlay})
+	if err != nil {
+		return nil, err
+	}
+	byFile := map[string][]iverson.Finding{}
+	for _, pkg := range iverson.DedupeTests(ps) {
+		// without Prefilter, which reads the files again from disk rather than the overlay
+		found, err := iverson.FindContext(ctx, pkg, iverson.Options{Imported: *imported, Returns: *returns, Helpers: alwaysHelpers, NotHelpers: neverHelpers})
+		if err != nil {
+			return nil, err
+		}
+		if !*generated {
+			found, _ = iverson.DropGenerated(pkg.Syntax, pkg.Fset, found)
+		}
+		if *intAsBool {
+			found = append(found, iverson.FindIntAsBool(pkg)...)
+		}
+		if *probable {
+			found = append(found, iverson.FindProbableHelpers(pkg)...)
+		}
+		if *defs {
+			found = append(found, iverson.FindDefinitions(pkg)...)
+		}
+		if *overlaps == "collapse" {
+			found = iverson.CollapseOverlaps(found)
+		}
+		for _, f := range found {
+			byFile[f.Pos.Filename] = append(byFile[f.Pos.Filename], f)
+		}
+	}
+	return byFile, nil
+}
+
+// publish publishes the diagnostics of found in the file at uri, with its contents f, with s.mu held.
+// If f is nil, it clears them.
+func (s *lspServer) publish(uri string, f *openFile, found []iverson.Finding) {
+	params := lspPublishDiagnostics{URI: uri, Diagnostics: []lspDiagnostic{}}
+	if f != nil {
+		params.Version = &f.version
+	}
+	for _, found := range found {
+		end := found.End
+		if !end.IsValid() {
+			end = found.Pos
+		}
+		msg := found.Message()
+		if found.Proposed != "" {
+			msg += ", proposed as " + found.Proposed
+		}
+		params.Diagnostics = append(params.Diagnostics, lspDiagnostic{
+			Range:    lspRange{lspPos(f.text, found.Pos.Offset), lspPos(f.text, end.Offset)},
+			Severity: lspSeverities[severity.of(found)],
+			Code:     found.Kind,
+			Source:   "issue61915",
+			Message:  msg,
+		})
+	}
+	s.send(lspNotification{"2.0", "textDocument/publishDiagnostics", params})
+}
+
+// respond sends the response to the request with id.
+func (s *lspServer) respond(id, result json.RawMessage, err *lspError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		result = nil
+	} else if result == nil {
+		result = json.RawMessage("null")
+	}
+	s.send(lspResponse{"2.0", id, result, err})
+}
+
+// send writes msg to the client, with s.mu held.
+func (s *lspServer) send(msg any) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(msg); err != nil {
+		panic(err)
+	}
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		slog.Error("could not write to the client", "err", err)
+	}
+}
+
+// lspPos returns the position of the byte offset in text, counting characters in UTF-16 code units.
+func lspPos(text []byte, offset int) lspPosition {
+	offset = min(max(offset, 0), len(text))
+	var p lspPosition
+	for i := 0; i < offset; {
+		r, n := utf8.DecodeRune(text[i:])
+		if r == '\n' {
+			p.Line++
+			p.Character = 0
+		} else {
+			p.Character += utf16.RuneLen(r)
+		}
+		i += n
+	}
+	return p
+}
+
+// uriPath returns the path of the file: uri.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI: %s", uri)
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir/file.go
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.Clean(filepath.FromSlash(path)), nil
+}
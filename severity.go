@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// A Severity ranks how important a kind of finding is.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func parseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if s == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q: want info, warning, or error", s)
+}
+
+// severities maps each kind of finding, or kind/form for the findings of a kind with one form,
+// like explicit/literal for reads of map literals, to its severity.
+// A kind/form overrides the kind, and kinds not listed are Info.
+type severities map[string]Severity
+
+// of returns the severity of f.
+func (s severities) of(f iverson.Finding) Severity {
+	if sev, ok := s[f.Kind+"/"+f.Form]; ok && f.Form != "" {
+		return sev
+	}
+	return s[f.Kind]
+}
+
+func defaultSeverities() severities {
+	return severities{
+		iverson.Implicit: Warning,
+		iverson.Explicit: Warning,
+	}
+}
+
+func (s severities) String() string {
+	var kv []string
+	for kind, sev := range s {
+		kv = append(kv, kind+"="+sev.String())
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, ",")
+}
+
+// Set parses a comma separated list of kind=severity or kind/form=severity.
+func (s severities) Set(v string) error {
+	for _, kv := range strings.Split(v, ",") {
+		key, level, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("%q is not kind=severity", kv)
+		}
+		key = strings.TrimSpace(key)
+		kind, form, hasForm := strings.Cut(key, "/")
+		if !slices.Contains(kinds, kind) && kind != iverson.Definition {
+			return fmt.Errorf("unknown kind %q: want one of %s, or %s", kind, strings.Join(kinds, ", "), iverson.Definition)
+		}
+		if hasForm && form == "" {
+			return fmt.Errorf("%q has no form after the /", kv)
+		}
+		sev, err := parseSeverity(strings.TrimSpace(level))
+		if err != nil {
+			return err
+		}
+		s[key] = sev
+	}
+	return nil
+}
+
+// failOn is the -fail-on flag: the least severity that fails the run, if set.
+type failOn struct {
+	set bool
+	sev Severity
+}
+
+func (f *failOn) String() string {
+	if !f.set {
+		return ""
+	}
+	return f.sev.String()
+}
+
+func (f *failOn) Set(v string) error {
+	sev, err := parseSeverity(v)
+	if err != nil {
+		return err
+	}
+	f.set, f.sev = true, sev
+	return nil
+}
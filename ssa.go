@@ -0,0 +1,156 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"slices"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+	"github.com/jimmyfrasche/issue61915/syntax"
+)
+
+// verifySSA builds SSA for pkg and sets the SSA field of its implicit findings:
+// "select" if both branches, or the only one and the statement initializing the variable before it,
+// set a local variable held in a register,
+// so that the if is truly a two-way choice between numbers,
+// or "memory" if the variable is stored in memory,
+// as when its address is taken, it is captured by a closure, or it is not a local at all,
+// and other code could observe or change it.
+func verifySSA(pkg *packages.Package, found []iverson.Finding) {
+	var ssapkg *ssa.Package
+	for i, f := range found {
+		if f.Kind != iverson.Implicit || f.Form == "return" {
+			// returning the numbers needs no variable to check
+			continue
+		}
+		if ssapkg == nil {
+			ssapkg = buildSSA(pkg)
+		}
+		found[i].SSA = "memory"
+		then, els, path := branchesAt(pkg, f)
+		if then == nil || els == nil {
+			continue
+		}
+		fn := ssa.EnclosingFunction(ssapkg, path)
+		if fn == nil {
+			continue
+		}
+		if inRegister(fn, pkg.TypesInfo, then) && inRegister(fn, pkg.TypesInfo, els) {
+			found[i].SSA = "select"
+		}
+	}
+}
+
+// buildSSA builds pkg, with debug information, and creates the packages it imports.
+func buildSSA(pkg *packages.Package) *ssa.Package {
+	prog := ssa.NewProgram(pkg.Fset, ssa.GlobalDebug)
+	created := map[*types.Package]bool{}
+	var createAll func([]*types.Package)
+	createAll = func(ps []*types.Package) {
+		for _, p := range ps {
+			if !created[p] {
+				created[p] = true
+				prog.CreatePackage(p, nil, nil, true)
+				createAll(p.Imports())
+			}
+		}
+	}
+	createAll(pkg.Types.Imports())
+	ssapkg := prog.CreatePackage(pkg.Types, pkg.Syntax, pkg.TypesInfo, false)
+	ssapkg.Build()
+	return ssapkg
+}
+
+// branchesAt returns the variables set by the branches of the implicit if or switch of the finding f,
+// or by its only branch and the statement initializing the variable before it,
+// and the path to it from the root of its file.
+func branchesAt(pkg *packages.Package, f iverson.Finding) (then, els ast.Expr, path []ast.Node) {
+	for _, file := range pkg.Syntax {
+		if pkg.Fset.Position(file.FileStart).Filename != f.Pos.Filename {
+			continue
+		}
+		pos := pkg.Fset.File(file.FileStart).Pos(f.Pos.Offset)
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for i, n := range path {
+			if n.Pos() != pos {
+				continue
+			}
+			switch n := n.(type) {
+			case *ast.IfStmt:
+				then := syntax.BranchAssign(n.Body).Lhs[0]
+				if els, ok := n.Else.(*ast.BlockStmt); ok {
+					return then, syntax.BranchAssign(els).Lhs[0], path
+				}
+				if i+1 < len(path) {
+					return then, initializedBefore(path[i+1], n), path
+				}
+			case *ast.SwitchStmt:
+				if _, then, els, ok := iverson.SwitchBranches(pkg, n); ok {
+					return syntax.BranchAssign(then).Lhs[0], syntax.BranchAssign(els).Lhs[0], path
+				}
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// initializedBefore returns the variable set by the statement before n in the block or clause parent,
+// as by x := 0 or var x int, or nil if there is none.
+func initializedBefore(parent ast.Node, n ast.Stmt) ast.Expr {
+	var list []ast.Stmt
+	switch p := parent.(type) {
+	case *ast.BlockStmt:
+		list = p.List
+	case *ast.CaseClause:
+		list = p.Body
+	case *ast.CommClause:
+		list = p.Body
+	}
+	i := slices.Index(list, n)
+	if i < 1 {
+		return nil
+	}
+	switch prev := list[i-1].(type) {
+	case *ast.AssignStmt:
+		if len(prev.Lhs) == 1 {
+			return prev.Lhs[0]
+		}
+	case *ast.DeclStmt:
+		if gen, ok := prev.Decl.(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+			if spec, ok := gen.Specs[0].(*ast.ValueSpec); ok && len(spec.Names) == 1 {
+				return spec.Names[0]
+			}
+		}
+	}
+	return nil
+}
+
+// inRegister reports whether the variable lhs is local to fn and was lifted to a register,
+// so it has no Alloc left and is not a free variable of a closure.
+func inRegister(fn *ssa.Function, info *types.Info, lhs ast.Expr) bool {
+	id, ok := ast.Unparen(lhs).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	v, ok := info.ObjectOf(id).(*types.Var)
+	if !ok || v.Parent() == v.Pkg().Scope() {
+		return false
+	}
+	for _, fv := range fn.FreeVars {
+		if fv.Pos() == v.Pos() {
+			return false
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if alloc, ok := instr.(*ssa.Alloc); ok && alloc.Pos() == v.Pos() {
+				return false
+			}
+		}
+	}
+	return true
+}
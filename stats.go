@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// runStats measures a run for sizing large scans.
+type runStats struct {
+	start, loadDone time.Time
+	packages, files int // analyzed
+	// detectors is the total time spent in each detector, like find or ssa,
+	// over all the packages analyzed at once
+	mu        sync.Mutex
+	detectors map[string]time.Duration
+}
+
+// loaded marks the end of loading and the start of analysis.
+func (s *runStats) loaded() {
+	s.loadDone = time.Now()
+}
+
+// timed runs f, the detector name for the package being analyzed,
+// adding the time it took to per, the times for that package, and to the totals.
+func (s *runStats) timed(per map[string]time.Duration, name string, f func()) {
+	start := time.Now()
+	f()
+	d := time.Since(start)
+	per[name] += d
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.detectors == nil {
+		s.detectors = map[string]time.Duration{}
+	}
+	s.detectors[name] += d
+}
+
+func (s *runStats) log() {
+	now := time.Now()
+	wall := now.Sub(s.start)
+	attrs := []any{"wall", wall}
+	if !s.loadDone.IsZero() {
+		analysis := now.Sub(s.loadDone)
+		attrs = append(attrs,
+			"load", s.loadDone.Sub(s.start),
+			"analysis", analysis,
+			"packages", s.packages,
+			"files", s.files,
+			"packages_per_sec", rate(s.packages, wall),
+			"files_per_sec", rate(s.files, analysis),
+		)
+		if len(s.detectors) > 0 {
+			attrs = append(attrs, "detectors", s.detectors)
+		}
+	}
+	if cpu, childCPU, maxRSS, ok := resourceUsage(); ok {
+		attrs = append(attrs, "cpu", cpu, "child_cpu", childCPU, "max_rss", maxRSS)
+	}
+	slog.Debug("run stats", attrs...)
+}
+
+func rate(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// status is what the run of Main or Selftest reports to -status-file.
+var status runStatus
+
+// runStatus is the -status-file record, written at the end of every run whatever the -format,
+// for CI to check without parsing the report.
+type runStatus struct {
+	// Exit is the reason for the exit status:
+	// "ok", "fail-on" for findings at or above the -fail-on severity,
+	// "load-error" for packages that did not load, "interrupted" if the run was interrupted,
+	// or "error" for any other error.
+	Exit   string                 `json:"exit"`
+	Status int                    `json:"status"` // exit status
+	Error  string                 `json:"error,omitempty"`
+	Errors []iverson.PackageError `json:"errors,omitempty"` // with "load-error"
+	// Failed are the -targets-file targets or corpus modules that failed to load and were skipped, with why
+	Failed map[string]string `json:"failed,omitempty"`
+
+	Total    map[string]int    `json:"total"`
+	FailOn   string            `json:"fail_on,omitempty"` // -fail-on severity, if set
+	Failing  int               `json:"failing"`           // findings at or above it
+	Severity map[string]string `json:"severity"`          // of each kind, and kind/form set apart
+
+	Packages int     `json:"packages"` // analyzed
+	Files    int     `json:"files"`
+	Wall     float64 `json:"wall_seconds"`
+	Load     float64 `json:"load_seconds,omitempty"`
+	Analysis float64 `json:"analysis_seconds,omitempty"`
+
+	start time.Time
+	stats *runStats
+}
+
+// writeStatus writes status to the file name for a run that returned err.
+func writeStatus(name string, err error) error {
+	s := status
+	s.Exit = "ok"
+	var lerr *iverson.LoadError
+	switch {
+	case err == nil:
+	case errors.As(err, &lerr):
+		s.Exit, s.Errors = "load-error", lerr.Errors
+	case errors.Is(err, context.Canceled):
+		s.Exit = "interrupted"
+	case s.Failing > 0:
+		s.Exit = "fail-on"
+	default:
+		s.Exit = "error"
+	}
+	if err != nil {
+		s.Status, s.Error = 1, err.Error()
+	}
+	if s.Total == nil {
+		s.Total = map[string]int{}
+	}
+	if failLevel.set {
+		s.FailOn = failLevel.sev.String()
+	}
+	s.Severity = map[string]string{}
+	for _, kind := range kinds {
+		s.Severity[kind] = severity[kind].String()
+	}
+	for key, sev := range severity {
+		if strings.Contains(key, "/") {
+			s.Severity[key] = sev.String()
+		}
+	}
+	now := time.Now()
+	s.Wall = now.Sub(s.start).Seconds()
+	if st := s.stats; st != nil {
+		s.Packages, s.Files = st.packages, st.files
+		if !st.loadDone.IsZero() {
+			s.Load, s.Analysis = st.loadDone.Sub(st.start).Seconds(), now.Sub(st.loadDone).Seconds()
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
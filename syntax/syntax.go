@@ -0,0 +1,171 @@
+// Package syntax recognizes the shapes of bool to number conversions from syntax alone,
+// for tools like code generators and formatters that have an AST but no type information.
+//
+// Without types, a shape can only suggest a conversion:
+// the variable set by an Iverson if may not be a number at all, and true may be shadowed.
+// The iverson package confirms each shape with go/types before counting it.
+package syntax
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// BranchAssign returns the only statement of body if it is an assignment, or nil.
+// Nested blocks are looked through and the assignment may be followed by a bare return,
+// as when setting a named result, which BranchReturns reports.
+func BranchAssign(body *ast.BlockStmt) *ast.AssignStmt {
+	list, _ := branch(body)
+	if len(list) != 1 {
+		return nil
+	}
+	assign, _ := list[0].(*ast.AssignStmt)
+	return assign
+}
+
+// BranchReturns reports whether the assignment of body, as by BranchAssign, is followed by a bare return.
+func BranchReturns(body *ast.BlockStmt) bool {
+	_, returns := branch(body)
+	return returns
+}
+
+// SameBranches reports whether the branches then and els, each an assignment as by BranchAssign,
+// set the same variable and either both return after it or neither does,
+// so that together they only choose the value set.
+func SameBranches(then, els *ast.BlockStmt) bool {
+	a, b := BranchAssign(then), BranchAssign(els)
+	return a != nil && b != nil && len(a.Lhs) == 1 && len(b.Lhs) == 1 &&
+		SameExpr(a.Lhs[0], b.Lhs[0]) && BranchReturns(then) == BranchReturns(els)
+}
+
+// branch returns the statements of body, looking through nested blocks,
+// without a bare return after the first and whether there was one.
+func branch(body *ast.BlockStmt) ([]ast.Stmt, bool) {
+	list := body.List
+	for len(list) == 1 {
+		block, ok := list[0].(*ast.BlockStmt)
+		if !ok {
+			break
+		}
+		list = block.List
+	}
+	if len(list) == 2 {
+		if ret, ok := list[1].(*ast.ReturnStmt); ok && len(ret.Results) == 0 {
+			return list[:1], true
+		}
+	}
+	return list, false
+}
+
+// IversonIf reports whether n is an if-else whose branches only set the same variable lhs with =,
+// and either both return after or neither does,
+// to then if the condition is true and to els if it is false,
+// where each is a basic literal or an identifier, like
+//
+//	if b {
+//		x = 1
+//	} else {
+//		x = 0
+//	}
+func IversonIf(n *ast.IfStmt) (lhs, then, els ast.Expr, ok bool) {
+	block, ok := n.Else.(*ast.BlockStmt)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	var x [2]*ast.AssignStmt
+	for i, body := range []*ast.BlockStmt{n.Body, block} {
+		assign := BranchAssign(body)
+		if assign == nil || assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			return nil, nil, nil, false
+		}
+		switch assign.Rhs[0].(type) {
+		case *ast.BasicLit, *ast.Ident:
+		default:
+			return nil, nil, nil, false
+		}
+		x[i] = assign
+	}
+	if !SameBranches(n.Body, block) {
+		return nil, nil, nil, false
+	}
+	return x[0].Lhs[0], x[0].Rhs[0], x[1].Rhs[0], true
+}
+
+// ZeroOrOne returns the value of x if it is the integer literal 0 or 1, in any base.
+func ZeroOrOne(x ast.Expr) (int, bool) {
+	lit, ok := ast.Unparen(x).(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(strings.ReplaceAll(lit.Value, "_", ""), 0, 64)
+	if err != nil || v != 0 && v != 1 {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// MapLiteralIndex reports whether n reads from a map literal from bool to a predeclared number type
+// keyed by true and false, like map[bool]int{true: 1}[b], and returns the literal.
+func MapLiteralIndex(n *ast.IndexExpr) (*ast.CompositeLit, bool) {
+	lit, ok := ast.Unparen(n.X).(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+	m, ok := lit.Type.(*ast.MapType)
+	if !ok || !isIdent(m.Key, "bool") {
+		return nil, false
+	}
+	elem, ok := m.Value.(*ast.Ident)
+	if !ok || !numberTypes[elem.Name] {
+		return nil, false
+	}
+	for _, e := range lit.Elts {
+		kv, ok := e.(*ast.KeyValueExpr)
+		if !ok || !isIdent(kv.Key, "true") && !isIdent(kv.Key, "false") {
+			return nil, false
+		}
+	}
+	return lit, true
+}
+
+// numberTypes are the names of the predeclared number types.
+var numberTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"byte": true, "rune": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+func isIdent(x ast.Expr, name string) bool {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// SameExpr reports whether x and y are the same variable, field, element, or dereference,
+// like a.b[i] and (a.b)[i], by their syntax.
+func SameExpr(x, y ast.Expr) bool {
+	x, y = ast.Unparen(x), ast.Unparen(y)
+	switch x := x.(type) {
+	case *ast.Ident:
+		y, ok := y.(*ast.Ident)
+		return ok && x.Name == y.Name
+	case *ast.SelectorExpr:
+		y, ok := y.(*ast.SelectorExpr)
+		return ok && x.Sel.Name == y.Sel.Name && SameExpr(x.X, y.X)
+	case *ast.IndexExpr:
+		y, ok := y.(*ast.IndexExpr)
+		return ok && SameExpr(x.X, y.X) && (SameExpr(x.Index, y.Index) || sameLit(x.Index, y.Index))
+	case *ast.StarExpr:
+		y, ok := y.(*ast.StarExpr)
+		return ok && SameExpr(x.X, y.X)
+	}
+	return false
+}
+
+func sameLit(x, y ast.Expr) bool {
+	a, ok := ast.Unparen(x).(*ast.BasicLit)
+	b, ok2 := ast.Unparen(y).(*ast.BasicLit)
+	return ok && ok2 && a.Kind == b.Kind && a.Value == b.Value
+}
@@ -0,0 +1,99 @@
+package syntax
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// parse returns the first node of type T in the body of a func containing src.
+func parse[T ast.Node](t *testing.T, src string) T {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\nfunc f() {\n"+src+"\n}", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found T
+	ok := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if x, is := n.(T); is && !ok {
+			found, ok = x, true
+		}
+		return !ok
+	})
+	if !ok {
+		t.Fatalf("no %T in %q", found, src)
+	}
+	return found
+}
+
+func TestIversonIf(t *testing.T) {
+	for _, tt := range []struct {
+		src       string
+		then, els string
+		want      bool
+	}{
+		{"if b { x = 1 } else { x = 0 }", "1", "0", true},
+		{"if b { s.f[2] = one } else { (s.f)[2] = zero }", "one", "zero", true},
+		{"if b { x = 1; return } else { { x = 0; return } }", "1", "0", true},
+		{"if b { x = 1; return } else { x = 0 }", "", "", false},
+		{"if b { x = 1 } else { y = 0 }", "", "", false},
+		{"if b { x = 1 } else if c { x = 0 }", "", "", false},
+		{"if b { x = 1 }", "", "", false},
+		{"if b { x := 1 } else { x := 0 }", "", "", false},
+		{"if b { x = f() } else { x = 0 }", "", "", false},
+		{"if b { x = 1; println() } else { x = 0 }", "", "", false},
+	} {
+		_, then, els, ok := IversonIf(parse[*ast.IfStmt](t, tt.src))
+		if ok != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.src, ok, tt.want)
+			continue
+		}
+		if ok && (lit(then) != tt.then || lit(els) != tt.els) {
+			t.Errorf("%s: got %s, %s, want %s, %s", tt.src, lit(then), lit(els), tt.then, tt.els)
+		}
+	}
+}
+
+func lit(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		return x.Value
+	case *ast.Ident:
+		return x.Name
+	}
+	return ""
+}
+
+func TestZeroOrOne(t *testing.T) {
+	for src, want := range map[string]int{"0": 0, "1": 1, "0x1": 1, "(0)": 0, "0_0": 0, "2": -1, "1.0": -1, "one": -1} {
+		got, ok := ZeroOrOne(parse[*ast.ExprStmt](t, src).X)
+		if !ok {
+			got = -1
+		}
+		if got != want {
+			t.Errorf("ZeroOrOne(%s) = %d, want %d", src, got, want)
+		}
+	}
+}
+
+func TestMapLiteralIndex(t *testing.T) {
+	for src, want := range map[string]bool{
+		"_ = map[bool]int{true: 1}[b]":                      true,
+		"_ = (map[bool]float64{false: 0, true: 1.5})[b]":    true,
+		"_ = map[bool]int{}[b]":                             true,
+		"_ = map[bool]string{true: \"yes\"}[b]":             false,
+		"_ = map[string]int{\"a\": 1}[s]":                   false,
+		"_ = map[bool]int{x: 1}[b]":                         false,
+		"_ = m[b]":                                          false,
+		"_ = []int{0, 1}[i]":                                false,
+		"_ = map[bool]myInt{true: 1}[b]":                    false,
+		"_ = map[bool]uint8{true: 1, false: 0}[x > y && z]": true,
+	} {
+		_, got := MapLiteralIndex(parse[*ast.IndexExpr](t, src))
+		if got != want {
+			t.Errorf("MapLiteralIndex(%s) = %v, want %v", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// readTargets reads the -targets-file name, or stdin if name is -.
+// It has one target per line: a package pattern, a directory, or the root directory of a module.
+// Blank lines and lines starting with # are ignored.
+func readTargets(name string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var targets []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		targets = append(targets, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%s: no targets", name)
+	}
+	return targets, nil
+}
+
+// targetPattern returns the directory to load target from and the patterns to load:
+// every package in a module for its root directory,
+// the package in any other directory,
+// and otherwise the target itself as a pattern.
+func targetPattern(target string) (dir string, pattern []string) {
+	if fi, err := os.Stat(target); err == nil && fi.IsDir() {
+		if _, err := os.Stat(filepath.Join(target, "go.mod")); err == nil {
+			return target, []string{"./..."}
+		}
+		if !filepath.IsAbs(target) {
+			target = "." + string(filepath.Separator) + filepath.Clean(target)
+		}
+	}
+	return "", []string{target}
+}
+
+// loadTargets loads the packages of each target separately,
+// so that one that fails to load is logged and skipped rather than failing the run.
+// A package in more than one target is only included once.
+// It is an error if every target fails.
+func loadTargets(ctx context.Context, targets []string, opts iverson.LoadOptions) ([]*packages.Package, error) {
+	return loadEach(ctx, targets, func(target string) ([]*packages.Package, error) {
+		dir, pattern := targetPattern(target)
+		return iverson.Packages(ctx, dir, pattern, opts)
+	})
+}
+
+// loadEach loads the packages of each target with load, as loadTargets does,
+// and records each target that fails, with its error, in the status of the run.
+func loadEach(ctx context.Context, targets []string, load func(target string) ([]*packages.Package, error)) ([]*packages.Package, error) {
+	var ps []*packages.Package
+	seen := map[string]bool{}
+	failed := 0
+	for _, target := range targets {
+		tps, err := load(target)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			failed++
+			if status.Failed == nil {
+				status.Failed = map[string]string{}
+			}
+			status.Failed[target] = err.Error()
+			var lerr *iverson.LoadError
+			if errors.As(err, &lerr) {
+				slog.Error("skipping target", "target", target, "err", err, "errors", lerr.Errors)
+			} else {
+				slog.Error("skipping target", "target", target, "err", err)
+			}
+			continue
+		}
+		for _, pkg := range tps {
+			// IDs are only unique within one load, so tell packages apart by their files too
+			key := pkg.ID
+			if len(pkg.GoFiles) > 0 {
+				key += " " + pkg.GoFiles[0]
+			}
+			if !seen[key] {
+				seen[key] = true
+				ps = append(ps, pkg)
+			}
+		}
+	}
+	if failed == len(targets) {
+		return nil, fmt.Errorf("all %d targets failed to load", failed)
+	}
+	if failed > 0 {
+		slog.Warn("some targets failed to load", "failed", failed, "targets", len(targets))
+	}
+	return ps, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jimmyfrasche/issue61915/iverson"
+)
+
+// vizColors are the colors of the nodes of -viz by the most common kind of finding under them.
+var vizColors = map[string]string{
+	iverson.Implicit:   "#4e79a7",
+	iverson.Explicit:   "#f28e2b",
+	iverson.Degenerate: "#bab0ac",
+	iverson.RoundTrip:  "#e15759",
+	iverson.IntAsBool:  "#76b7b2",
+	iverson.Unverified: "#edc948",
+	iverson.Increment:  "#59a14f",
+	iverson.Probable:   "#b07aa1",
+	iverson.Definition: "#ff9da7",
+}
+
+// A vizNode is a package, or a prefix of the import paths of packages, in the -viz hierarchy.
+// Its JSON is the treemap format of d3.hierarchy, whose sum of value is the size of each node.
+type vizNode struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"`
+	Value    int            `json:"value"`  // findings in the package itself
+	Counts   map[string]int `json:"counts"` // findings under the node by kind
+	Kind     string         `json:"kind,omitempty"`
+	Color    string         `json:"color,omitempty"`
+	Children []*vizNode     `json:"children,omitempty"`
+
+	own map[string]int // findings in the package itself by kind
+}
+
+// vizTree arranges the packages with findings by their import paths,
+// merging each prefix that is not a package and has only one child into that child.
+func vizTree(ps []jsonPackage) *vizNode {
+	root := &vizNode{Name: "all"}
+	for _, p := range ps {
+		if p.ID == "" {
+			continue
+		}
+		n := root
+		for i, elem := range strings.Split(p.Path, "/") {
+			j := slices.IndexFunc(n.Children, func(c *vizNode) bool { return c.Name == elem })
+			if j < 0 {
+				child := &vizNode{Name: elem, Path: strings.Join(strings.Split(p.Path, "/")[:i+1], "/")}
+				n.Children = append(n.Children, child)
+				j = len(n.Children) - 1
+			}
+			n = n.Children[j]
+		}
+		if n.own == nil {
+			n.own = map[string]int{}
+		}
+		for kind, c := range p.Counts {
+			n.Value += c
+			n.own[kind] += c
+		}
+	}
+	root.finish()
+	return root
+}
+
+// finish sorts and merges the descendants of n and totals their counts.
+func (n *vizNode) finish() {
+	n.Counts = maps.Clone(n.own)
+	if n.Counts == nil {
+		n.Counts = map[string]int{}
+	}
+	for i, c := range n.Children {
+		for len(c.Children) == 1 && c.Value == 0 {
+			only := c.Children[0]
+			only.Name = c.Name + "/" + only.Name
+			c = only
+		}
+		n.Children[i] = c
+		c.finish()
+		for kind, count := range c.Counts {
+			n.Counts[kind] += count
+		}
+	}
+	slices.SortFunc(n.Children, func(a, b *vizNode) int { return strings.Compare(a.Name, b.Name) })
+	best := 0
+	for _, kind := range kinds {
+		if n.Counts[kind] > best {
+			n.Kind, best = kind, n.Counts[kind]
+		}
+	}
+	n.Color = vizColors[n.Kind]
+}
+
+// writeViz writes the packages with findings as format: a DOT graph, or a treemap in JSON.
+func writeViz(w io.Writer, format string, ps []jsonPackage) error {
+	root := vizTree(ps)
+	if format == "treemap" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(root)
+	}
+	var b strings.Builder
+	b.WriteString("digraph findings {\n\trankdir=LR;\n\tnode [shape=box, style=filled, fontcolor=white];\n")
+	var walk func(*vizNode)
+	walk = func(n *vizNode) {
+		for _, c := range n.Children {
+			fmt.Fprintf(&b, "\t%q [label=%q, fillcolor=%q];\n", c.Path, c.Name+"\n"+summary(c.Counts), c.Color)
+			if n != root {
+				fmt.Fprintf(&b, "\t%q -> %q;\n", n.Path, c.Path)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}